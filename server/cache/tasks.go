@@ -0,0 +1,54 @@
+package cache
+
+import (
+	"encoding/json"
+	"fmt"
+	"server/initializers"
+	"server/models"
+	"time"
+)
+
+const (
+	TasksCachePrefix = "tasks:"
+	TasksCacheTTL    = 15 * time.Minute
+)
+
+// filters of a task list, empty values mean not filtering
+type TaskListFilters struct {
+	HideCompleted bool
+	Today         string //user local date
+	Due           string //due filter with the user local date it starts on
+	Project       string //project id or none
+	Tag           string //normalized tag name
+	Sort          string
+}
+
+// Generate cache key for task lists with filters
+func GetTasksListCacheKey(userID uint, filters TaskListFilters) string {
+	return fmt.Sprintf("%s%d:hideCompleted:%t:today:%s:due:%s:project:%s:tag:%s:sort:%s",
+		TasksCachePrefix, userID, filters.HideCompleted, filters.Today, filters.Due, filters.Project, filters.Tag, filters.Sort)
+}
+
+// cache tasks list under its filter key
+func CacheTaskList(key string, tasks []models.TasksModel) error {
+	tasksJSON, err := json.Marshal(tasks)
+	if err != nil {
+		return err
+	}
+
+	return initializers.RedisClient.Set(initializers.Ctx, key, tasksJSON, TasksCacheTTL).Err()
+}
+
+// invalidate all task caches for a user
+func InvalidateUserTaskCaches(userID uint) {
+	// get all keys with the users prefix
+	pattern := fmt.Sprintf("%s%d:*", TasksCachePrefix, userID)
+	keys, err := initializers.RedisClient.Keys(initializers.Ctx, pattern).Result()
+	if err != nil {
+		return
+	}
+
+	if len(keys) > 0 {
+		initializers.RedisClient.Del(initializers.Ctx, keys...)
+	}
+}
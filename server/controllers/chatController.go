@@ -8,8 +8,10 @@ import (
 	"log"
 	"net/http"
 	"server/models"
+	"server/utils"
 	"sort"
 	"sync"
+	"time"
 )
 
 // message format
@@ -21,10 +23,11 @@ type Message struct {
 
 // Connection with ws
 type Connection struct {
-	ws   *websocket.Conn
-	send chan Message
-	room *Room
-	id   string //uniqueID
+	ws     *websocket.Conn
+	send   chan Message
+	room   *Room
+	id     string //uniqueID
+	userID uint
 }
 
 // chat room
@@ -135,10 +138,11 @@ func ChatSocket(c *gin.Context) {
 	log.Printf("WebSocket connection established for user %s in room %s", userA, roomID)
 
 	conn := &Connection{
-		ws:   ws,
-		send: make(chan Message, 256),
-		room: room,
-		id:   userA,
+		ws:     ws,
+		send:   make(chan Message, 256),
+		room:   room,
+		id:     userA,
+		userID: currentUser.ID,
 	}
 
 	room.mx.Lock()
@@ -220,6 +224,7 @@ func (c *Connection) readPump() {
 		select {
 		case c.room.broadcast <- msg:
 			log.Printf("Message from %s successfully sent to broadcast", c.id)
+			go utils.RecordActivity(c.userID, utils.ActivityChatMessage, time.Now())
 		default:
 			log.Printf("Room broadcast channel is full, dropping message from %s", c.id)
 		}
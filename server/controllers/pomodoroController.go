@@ -4,7 +4,9 @@ import (
 	"errors"
 
 	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
 	"gorm.io/gorm"
+	"log"
 	"net/http"
 	"server/cache"
 	"server/initializers"
@@ -12,51 +14,91 @@ import (
 	"server/utils"
 )
 
-func UpdatePomodoroSettings(c *gin.Context) {
-	var body struct {
-		Pomodoro       int  `json:"pomodoro"`
-		ShortBreak     int  `json:"shortBreak"`
-		LongBreak      int  `json:"longBreak"`
-		AutoTransition bool `json:"autoTransition"`
+// timer configuration shared by the settings and profile endpoints
+type timerConfig struct {
+	Pomodoro          int                `json:"pomodoro"`
+	ShortBreak        int                `json:"shortBreak"`
+	LongBreak         int                `json:"longBreak"`
+	LongBreakInterval int                `json:"longBreakInterval"`
+	Sequence          []models.PhaseStep `json:"sequence"` //empty keeps the classic cycle
+	AutoTransition    bool               `json:"autoTransition"`
+}
+
+// validate the config, responds with an error when invalid
+func validateTimerConfig(c *gin.Context, config *timerConfig) bool {
+	if config.Pomodoro < 1 || config.Pomodoro > 60 {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Pomodoro duration must be between 1 and 60 minutes"})
+		return false
 	}
 
-	if c.Bind(&body) != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Failed to read body"})
-		return
+	if config.ShortBreak < 1 || config.ShortBreak > 60 {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Short break duration must be between 1 and 60 minutes"})
+		return false
+	}
+	if config.LongBreak < 1 || config.LongBreak > 60 {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Long break duration must be between 1 and 60 minutes"})
+		return false
 	}
 
-	if body.Pomodoro < 1 || body.Pomodoro > 60 {
-		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Pomodoro duration must be between 1 and 60 minutes"})
-		return
+	//older clients dont send the interval
+	if config.LongBreakInterval == 0 {
+		config.LongBreakInterval = 4
+	}
+	if config.LongBreakInterval < 1 || config.LongBreakInterval > 12 {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Long break interval must be between 1 and 12 pomodoros"})
+		return false
 	}
 
-	if body.ShortBreak < 1 || body.ShortBreak > 60 {
-		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Short break duration must be between 1 and 60 minutes"})
+	if len(config.Sequence) > 0 && !utils.IsValidPhaseSequence(config.Sequence) {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Sequence must have 1-20 phases with names up to 30 characters, durations between 1 and 180 minutes and at least one focus phase"})
+		return false
+	}
+
+	return true
+}
+
+// copy the config to the per user timer
+func applyTimerConfig(settings *models.PomodoroModel, config timerConfig) {
+	settings.PomodoroDuration = config.Pomodoro
+	settings.ShortBreakDuration = config.ShortBreak
+	settings.LongBreakDuration = config.LongBreak
+	settings.LongBreakInterval = config.LongBreakInterval
+	settings.AutoTransition = config.AutoTransition
+	utils.SetPhaseSequence(settings, config.Sequence)
+}
+
+func UpdatePomodoroSettings(c *gin.Context) {
+	var body timerConfig
+
+	if c.Bind(&body) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Failed to read body"})
 		return
 	}
-	if body.LongBreak < 1 || body.LongBreak > 60 {
-		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Long break duration must be between 1 and 60 minutes"})
+
+	if !validateTimerConfig(c, &body) {
 		return
 	}
 
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
 	//get settings using cache
 	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			settings = models.PomodoroModel{
-				UserID:             currentUser.ID,
-				PomodoroDuration:   body.Pomodoro,
-				ShortBreakDuration: body.ShortBreak,
-				LongBreakDuration:  body.LongBreak,
-				AutoTransition:     body.AutoTransition,
+				UserID:       currentUser.ID,
+				CurrentPhase: "pomodoro",
 			}
+			applyTimerConfig(&settings, body)
 			initializers.DB.Create(&settings)
 
 			//add new settings to cache
 			cache.CachePomodoroSettings(settings)
+			utils.BroadcastPomodoro(settings, "settingsUpdated")
 		} else {
 			c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to fetch pomodoro settings"})
 			return
@@ -65,14 +107,21 @@ func UpdatePomodoroSettings(c *gin.Context) {
 		//invalidate cache before update
 		cache.InvalidatePomodoroCache(currentUser.ID)
 
-		settings.PomodoroDuration = body.Pomodoro
-		settings.ShortBreakDuration = body.ShortBreak
-		settings.LongBreakDuration = body.LongBreak
-		settings.AutoTransition = body.AutoTransition
+		applyTimerConfig(&settings, body)
 		initializers.DB.Save(&settings)
 
+		//active profile follows the edited settings
+		if settings.ActiveProfileID != nil {
+			var profile models.PomodoroProfile
+			if err := initializers.DB.Where("id = ? AND user_id = ?", *settings.ActiveProfileID, currentUser.ID).First(&profile).Error; err == nil {
+				applyProfileConfig(&profile, body)
+				initializers.DB.Save(&profile)
+			}
+		}
+
 		//update cache with new settings
 		cache.CachePomodoroSettings(settings)
+		utils.BroadcastPomodoro(settings, "settingsUpdated")
 	}
 
 	c.JSON(http.StatusOK, gin.H{"success": "Settings updated successfully"})
@@ -90,13 +139,17 @@ func GetPomodoroSettings(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"pomodoro":       settings.PomodoroDuration,
-		"shortBreak":     settings.ShortBreakDuration,
-		"longBreak":      settings.LongBreakDuration,
-		"remainingTime":  settings.RemainingTime,
-		"isRunning":      settings.IsRunning,
-		"currentPhase":   settings.CurrentPhase,
-		"autoTransition": settings.AutoTransition,
+		"pomodoro":          settings.PomodoroDuration,
+		"shortBreak":        settings.ShortBreakDuration,
+		"longBreak":         settings.LongBreakDuration,
+		"longBreakInterval": settings.LongBreakInterval,
+		"sequence":          settings.PhaseSequence,
+		"remainingTime":     utils.RemainingTime(settings),
+		"isRunning":         settings.IsRunning,
+		"state":             utils.TimerState(settings),
+		"currentPhase":      settings.CurrentPhase,
+		"autoTransition":    settings.AutoTransition,
+		"activeProfileId":   settings.ActiveProfileID,
 	})
 }
 
@@ -111,15 +164,7 @@ func FetchPomodoroStatus(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-
-		"remainingTime":           settings.RemainingTime,
-		"isRunning":               settings.IsRunning,
-		"currentPhase":            settings.CurrentPhase,
-		"completedPomodoros":      settings.CompletedPomodoros,
-		"totalCompletedPomodoros": settings.TotalCompletedPomodoros,
-		"autoTransition":          settings.AutoTransition,
-	})
+	c.JSON(http.StatusOK, utils.PomodoroStatus(settings))
 }
 
 func StartPomodoro(c *gin.Context) {
@@ -127,7 +172,8 @@ func StartPomodoro(c *gin.Context) {
 	currentUser := user.(models.User)
 
 	var body struct {
-		Phase string `json:"phase"`
+		Phase   string `json:"phase"`
+		LocalID *uint  `json:"localId"` //optional task to track focused time against
 	}
 
 	if c.Bind(&body) != nil {
@@ -135,6 +181,17 @@ func StartPomodoro(c *gin.Context) {
 		return
 	}
 
+	if body.LocalID != nil {
+		var task models.TasksModel
+		if err := initializers.DB.Where("local_id = ? AND user_id = ?", *body.LocalID, currentUser.ID).First(&task).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"errorTimer": "Cant find a task!"})
+			return
+		}
+	}
+
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
 	//get settings using cache
 	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
 	if err != nil {
@@ -142,8 +199,18 @@ func StartPomodoro(c *gin.Context) {
 		return
 	}
 
-	if settings.IsRunning {
-		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Timer already running"})
+	//empty phase starts the current one
+	if body.Phase == "" {
+		body.Phase = settings.CurrentPhase
+	}
+
+	if !utils.IsValidPhase(settings, body.Phase) {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Unknown phase"})
+		return
+	}
+
+	if !utils.CanTransition(settings, "start") {
+		c.JSON(http.StatusConflict, gin.H{"errorTimer": "Timer cant be started while " + utils.TimerState(settings), "state": utils.TimerState(settings)})
 		return
 	}
 
@@ -151,29 +218,23 @@ func StartPomodoro(c *gin.Context) {
 	cache.InvalidatePomodoroCache(currentUser.ID)
 
 	if settings.CurrentPhase != body.Phase {
-		settings.CurrentPhase = body.Phase
-		switch settings.CurrentPhase {
-		case "pomodoro":
-			settings.RemainingTime = settings.PomodoroDuration * 60
-		case "shortBreak":
-			settings.RemainingTime = settings.ShortBreakDuration * 60
-		case "longBreak":
-			settings.RemainingTime = settings.LongBreakDuration * 60
-		}
+		utils.SelectPhase(&settings, body.Phase)
 	}
 
-	settings.IsRunning = true
+	settings.TaskLocalID = body.LocalID
+	utils.StartPomodoroTimer(&settings)
 	initializers.DB.Save(&settings)
 
 	//update cache with new settings
 	cache.CachePomodoroSettings(settings)
-
-	utils.StartPomodoroTimer(currentUser.ID)
+	utils.BroadcastPomodoro(settings, "started")
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":       "Timer started successfully",
 		"currentPhase":  settings.CurrentPhase,
 		"remainingTime": settings.RemainingTime,
+		"phaseEndsAt":   settings.PhaseEndsAt,
+		"taskId":        settings.TaskLocalID,
 	})
 }
 
@@ -181,6 +242,9 @@ func StopPomodoro(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
 	//get settings using cache
 	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
 	if err != nil {
@@ -188,17 +252,18 @@ func StopPomodoro(c *gin.Context) {
 		return
 	}
 
-	if !settings.IsRunning {
-		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Timer is not running"})
+	if !utils.CanTransition(settings, "stop") {
+		c.JSON(http.StatusConflict, gin.H{"errorTimer": "Timer is not running", "state": utils.TimerState(settings)})
 		return
 	}
 
 	cache.InvalidatePomodoroCache(currentUser.ID)
 
-	settings.IsRunning = false
+	utils.StopPomodoroTimer(&settings)
 	initializers.DB.Save(&settings)
 
 	cache.CachePomodoroSettings(settings)
+	utils.BroadcastPomodoro(settings, "stopped")
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":       "Timer stopped successfully",
@@ -221,6 +286,9 @@ func ChangePhase(c *gin.Context) {
 		return
 	}
 
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
 	//get settings using cache
 	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
 	if err != nil {
@@ -228,28 +296,90 @@ func ChangePhase(c *gin.Context) {
 		return
 	}
 
+	if !utils.IsValidPhase(settings, body.Phase) {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Unknown phase"})
+		return
+	}
+
 	if settings.CurrentPhase != body.Phase {
 
 		cache.InvalidatePomodoroCache(currentUser.ID)
 
-		settings.CurrentPhase = body.Phase
-		switch settings.CurrentPhase {
-		case "pomodoro":
-			settings.RemainingTime = settings.PomodoroDuration * 60
-		case "shortBreak":
-			settings.RemainingTime = settings.ShortBreakDuration * 60
-		case "longBreak":
-			settings.RemainingTime = settings.LongBreakDuration * 60
+		//started run is aborted, a running timer restarts with the new phase
+		wasRunning := settings.IsRunning
+		if utils.CanTransition(settings, "stop") {
+			utils.StopPomodoroTimer(&settings)
+		}
+
+		utils.SelectPhase(&settings, body.Phase)
+
+		if wasRunning {
+			utils.StartPomodoroTimer(&settings)
 		}
 	}
 
 	initializers.DB.Save(&settings)
 
 	cache.CachePomodoroSettings(settings)
+	utils.BroadcastPomodoro(settings, "phaseChanged")
 
 	c.JSON(http.StatusOK, gin.H{"success": "Phase changed", "currentPhase": settings.CurrentPhase})
 }
 
+// shared flow of the pause/resume/skip/abandon endpoints
+func changeTimerState(c *gin.Context, action string, apply func(settings *models.PomodoroModel), event string, success string) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
+	//get settings using cache
+	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errorTimer": "Pomodoro setting not found"})
+		return
+	}
+
+	state := utils.TimerState(settings)
+	if !utils.CanTransition(settings, action) {
+		c.JSON(http.StatusConflict, gin.H{"errorTimer": "Cant " + action + " timer while " + state, "state": state})
+		return
+	}
+
+	cache.InvalidatePomodoroCache(currentUser.ID)
+
+	apply(&settings)
+
+	if err := initializers.DB.Save(&settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to update timer"})
+		return
+	}
+
+	cache.CachePomodoroSettings(settings)
+	utils.BroadcastPomodoro(settings, event)
+
+	status := utils.PomodoroStatus(settings)
+	status["success"] = success
+	c.JSON(http.StatusOK, status)
+}
+
+func PausePomodoro(c *gin.Context) {
+	changeTimerState(c, "pause", utils.PausePomodoroTimer, "paused", "Timer paused")
+}
+
+func ResumePomodoro(c *gin.Context) {
+	changeTimerState(c, "resume", utils.ResumePomodoroTimer, "resumed", "Timer resumed")
+}
+
+func SkipPomodoroPhase(c *gin.Context) {
+	changeTimerState(c, "skip", utils.SkipPomodoroPhase, "skipped", "Phase skipped")
+}
+
+func AbandonPomodoro(c *gin.Context) {
+	changeTimerState(c, "abandon", utils.AbandonPomodoroTimer, "abandoned", "Timer abandoned")
+}
+
 func UpdateAutoTransition(c *gin.Context) {
 
 	var body struct {
@@ -268,6 +398,9 @@ func UpdateAutoTransition(c *gin.Context) {
 	}
 	currentUser := user.(models.User)
 
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
 	//get settings using cache
 	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
 	if err != nil {
@@ -290,6 +423,7 @@ func UpdateAutoTransition(c *gin.Context) {
 	}
 
 	cache.CachePomodoroSettings(settings)
+	utils.BroadcastPomodoro(settings, "autoModeChanged")
 
 	c.JSON(http.StatusOK, gin.H{
 		"success":        "Auto transition updated successfully",
@@ -301,6 +435,9 @@ func ResetCompletedPomodoros(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
 	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
 	if err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
@@ -321,6 +458,35 @@ func ResetCompletedPomodoros(c *gin.Context) {
 	}
 
 	cache.CachePomodoroSettings(settings)
+	utils.BroadcastPomodoro(settings, "reset")
 
 	c.JSON(http.StatusOK, gin.H{"success": "Completed pomodoros reset to 0"})
 }
+
+// ws endpoint pushing timer state changes to every open tab/device
+func PomodoroSocket(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errorTimer": "Pomodoro setting not found"})
+		return
+	}
+
+	upgrader := websocket.Upgrader{
+		CheckOrigin: func(r *http.Request) bool {
+			return initializers.IsAllowedOrigin(r.Header.Get("Origin"))
+		},
+		ReadBufferSize:  1024,
+		WriteBufferSize: 1024,
+	}
+
+	ws, err := upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Failed to upgrade pomodoro connection: %v", err)
+		return
+	}
+
+	utils.ServePomodoroSocket(ws, settings)
+}
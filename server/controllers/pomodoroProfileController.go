@@ -0,0 +1,252 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"strconv"
+)
+
+const maxPomodoroProfiles = 20
+
+// profile body, timer config plus a name
+type profileInput struct {
+	Name string `json:"name"`
+	timerConfig
+}
+
+// copy a timer config to a profile
+func applyProfileConfig(profile *models.PomodoroProfile, config timerConfig) {
+	profile.PomodoroDuration = config.Pomodoro
+	profile.ShortBreakDuration = config.ShortBreak
+	profile.LongBreakDuration = config.LongBreak
+	profile.LongBreakInterval = config.LongBreakInterval
+	profile.PhaseSequence = config.Sequence
+	profile.AutoTransition = config.AutoTransition
+}
+
+// timer config stored in a profile
+func profileConfig(profile models.PomodoroProfile) timerConfig {
+	return timerConfig{
+		Pomodoro:          profile.PomodoroDuration,
+		ShortBreak:        profile.ShortBreakDuration,
+		LongBreak:         profile.LongBreakDuration,
+		LongBreakInterval: profile.LongBreakInterval,
+		Sequence:          profile.PhaseSequence,
+		AutoTransition:    profile.AutoTransition,
+	}
+}
+
+// read and validate a profile body, responds with an error when invalid
+func bindProfileInput(c *gin.Context, userID uint, exceptID uint) (profileInput, bool) {
+	var input profileInput
+
+	if c.Bind(&input) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Failed to read body"})
+		return input, false
+	}
+
+	if input.Name == "" || len(input.Name) > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Profile name must be between 1 and 50 characters"})
+		return input, false
+	}
+
+	if !validateTimerConfig(c, &input.timerConfig) {
+		return input, false
+	}
+
+	//names are unique per user
+	var count int64
+	initializers.DB.Model(&models.PomodoroProfile{}).
+		Where("user_id = ? AND name = ? AND id <> ?", userID, input.Name, exceptID).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"errorTimer": "Profile with this name already exists"})
+		return input, false
+	}
+
+	return input, true
+}
+
+// find a profile of the user from the url id, responds with an error when missing
+func findProfile(c *gin.Context, userID uint) (models.PomodoroProfile, bool) {
+	var profile models.PomodoroProfile
+
+	profileID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Wrong profile id"})
+		return profile, false
+	}
+
+	if err := initializers.DB.Where("id = ? AND user_id = ?", profileID, userID).First(&profile).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"errorTimer": "Profile not found"})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to fetch profile"})
+		}
+		return profile, false
+	}
+
+	return profile, true
+}
+
+func GetPomodoroProfiles(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var profiles []models.PomodoroProfile
+	if err := initializers.DB.Where("user_id = ?", currentUser.ID).Order("name asc").Find(&profiles).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to fetch profiles"})
+		return
+	}
+
+	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errorTimer": "Pomodoro setting not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": profiles, "activeProfileId": settings.ActiveProfileID})
+}
+
+func CreatePomodoroProfile(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	input, ok := bindProfileInput(c, currentUser.ID, 0)
+	if !ok {
+		return
+	}
+
+	var count int64
+	initializers.DB.Model(&models.PomodoroProfile{}).Where("user_id = ?", currentUser.ID).Count(&count)
+	if count >= maxPomodoroProfiles {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Profile limit reached"})
+		return
+	}
+
+	profile := models.PomodoroProfile{
+		UserID: currentUser.ID,
+		Name:   input.Name,
+	}
+	applyProfileConfig(&profile, input.timerConfig)
+
+	if err := initializers.DB.Create(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to create profile"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"data": profile})
+}
+
+func UpdatePomodoroProfile(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	profile, ok := findProfile(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	input, ok := bindProfileInput(c, currentUser.ID, profile.ID)
+	if !ok {
+		return
+	}
+
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
+	profile.Name = input.Name
+	applyProfileConfig(&profile, input.timerConfig)
+
+	if err := initializers.DB.Save(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to update profile"})
+		return
+	}
+
+	//editing the active profile updates the timer too
+	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
+	if err == nil && settings.ActiveProfileID != nil && *settings.ActiveProfileID == profile.ID {
+		cache.InvalidatePomodoroCache(currentUser.ID)
+
+		applyTimerConfig(&settings, input.timerConfig)
+		initializers.DB.Save(&settings)
+
+		cache.CachePomodoroSettings(settings)
+		utils.BroadcastPomodoro(settings, "settingsUpdated")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": profile})
+}
+
+func DeletePomodoroProfile(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	profile, ok := findProfile(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
+	if err := initializers.DB.Unscoped().Delete(&profile).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to delete profile"})
+		return
+	}
+
+	//timer keeps its durations, it is just no longer tied to a profile
+	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
+	if err == nil && settings.ActiveProfileID != nil && *settings.ActiveProfileID == profile.ID {
+		cache.InvalidatePomodoroCache(currentUser.ID)
+
+		settings.ActiveProfileID = nil
+		initializers.DB.Save(&settings)
+
+		cache.CachePomodoroSettings(settings)
+		utils.BroadcastPomodoro(settings, "settingsUpdated")
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": "Profile deleted"})
+}
+
+func ActivatePomodoroProfile(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	profile, ok := findProfile(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	unlock := utils.LockPomodoro(currentUser.ID)
+	defer unlock()
+
+	settings, err := cache.GetPomodoroSettingsByUserID(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"errorTimer": "Pomodoro setting not found"})
+		return
+	}
+
+	cache.InvalidatePomodoroCache(currentUser.ID)
+
+	//running phase keeps its deadline, new durations apply from the next phase
+	applyTimerConfig(&settings, profileConfig(profile))
+	settings.ActiveProfileID = &profile.ID
+
+	if err := initializers.DB.Save(&settings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to activate profile"})
+		return
+	}
+
+	cache.CachePomodoroSettings(settings)
+	utils.BroadcastPomodoro(settings, "profileActivated")
+
+	c.JSON(http.StatusOK, gin.H{"success": "Profile activated", "activeProfileId": settings.ActiveProfileID})
+}
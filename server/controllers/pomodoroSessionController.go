@@ -0,0 +1,74 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultSessionsLimit = 20
+	maxSessionsLimit     = 100
+)
+
+func GetPomodoroSessions(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	query := initializers.DB.Model(&models.PomodoroSession{}).Where("user_id = ?", currentUser.ID)
+
+	//optional date range, both bounds are days in YYYY-MM-DD format
+	if from := c.Query("from"); from != "" {
+		fromDate, err := time.ParseInLocation(time.DateOnly, from, utils.UserLocation(currentUser))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Invalid from date, expected YYYY-MM-DD"})
+			return
+		}
+		query = query.Where("ended_at >= ?", fromDate)
+	}
+
+	if to := c.Query("to"); to != "" {
+		toDate, err := time.ParseInLocation(time.DateOnly, to, utils.UserLocation(currentUser))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Invalid to date, expected YYYY-MM-DD"})
+			return
+		}
+		//to day is inclusive
+		query = query.Where("ended_at < ?", toDate.AddDate(0, 0, 1))
+	}
+
+	page, err := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if err != nil || page < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Invalid page"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSessionsLimit)))
+	if err != nil || limit < 1 || limit > maxSessionsLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"errorTimer": "Limit must be between 1 and 100"})
+		return
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to fetch pomodoro sessions"})
+		return
+	}
+
+	var sessions []models.PomodoroSession
+	if err := query.Order("ended_at desc").Offset((page - 1) * limit).Limit(limit).Find(&sessions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"errorTimer": "Failed to fetch pomodoro sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"data":  sessions,
+		"page":  page,
+		"limit": limit,
+		"total": total,
+	})
+}
@@ -0,0 +1,223 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+)
+
+const maxProjects = 50
+
+// whether the project belongs to the user
+func projectExists(userID uint, projectID uint) bool {
+	var count int64
+	initializers.DB.Model(&models.Project{}).Where("id = ? AND user_id = ?", projectID, userID).Count(&count)
+	return count > 0
+}
+
+// find a project of the user from the url id, responds with an error when missing
+func findProject(c *gin.Context, userID uint) (models.Project, bool) {
+	var project models.Project
+
+	projectID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong project id!"})
+		return project, false
+	}
+
+	if err := initializers.DB.Where("id = ? AND user_id = ?", projectID, userID).First(&project).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a project!"})
+		return project, false
+	}
+
+	return project, true
+}
+
+// read and validate a project name, responds with an error when invalid or taken
+func bindProjectName(c *gin.Context, userID uint, exceptID uint) (string, bool) {
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return "", false
+	}
+
+	name := strings.TrimSpace(input.Name)
+	if !utils.IsValidProjectName(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"projectNameError": "Project name must be between 1 and 50 characters!"})
+		return "", false
+	}
+
+	var count int64
+	initializers.DB.Model(&models.Project{}).
+		Where("user_id = ? AND name = ? AND id <> ?", userID, name, exceptID).
+		Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, gin.H{"projectNameError": "Project with this name already exists!"})
+		return "", false
+	}
+
+	return name, true
+}
+
+func GetProjects(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var projects []struct {
+		models.Project
+		TaskCount     int `json:"taskCount"`
+		OpenTaskCount int `json:"openTaskCount"`
+	}
+	err := initializers.DB.Model(&models.Project{}).
+		Select("projects.*, COUNT(tasks_models.id) AS task_count, COALESCE(SUM(tasks_models.completed = false), 0) AS open_task_count").
+		Joins("LEFT JOIN tasks_models ON tasks_models.project_id = projects.id AND tasks_models.deleted_at IS NULL").
+		Where("projects.user_id = ?", currentUser.ID).
+		Group("projects.id").
+		Order("projects.`order` asc, projects.id asc").
+		Scan(&projects).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch projects!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": projects})
+}
+
+func CreateProject(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	name, ok := bindProjectName(c, currentUser.ID, 0)
+	if !ok {
+		return
+	}
+
+	var count int64
+	initializers.DB.Model(&models.Project{}).Where("user_id = ?", currentUser.ID).Count(&count)
+	if count >= maxProjects {
+		c.JSON(http.StatusBadRequest, gin.H{"projectNameError": "You can have at most 50 projects!"})
+		return
+	}
+
+	project := models.Project{UserID: currentUser.ID, Name: name, Order: int(count) + 1}
+	if err := initializers.DB.Create(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant create a project!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": project})
+}
+
+func RenameProject(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	project, ok := findProject(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	name, ok := bindProjectName(c, currentUser.ID, project.ID)
+	if !ok {
+		return
+	}
+
+	project.Name = name
+	if err := initializers.DB.Save(&project).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant rename project!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": project})
+}
+
+// tasks of a deleted project move to the end of the inbox
+func DeleteProject(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	project, ok := findProject(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		offset := nextOrder(tx, currentUser.ID, nil)
+		moved := tx.Model(&models.TasksModel{}).Where("user_id = ? AND project_id = ?", currentUser.ID, project.ID)
+		if err := utils.RecordTaskChanges(tx, utils.TaskChangeUpsert, moved); err != nil {
+			return err
+		}
+
+		//trashed tasks move too, so a restore does not point to a missing project
+		if err := tx.Unscoped().Model(&models.TasksModel{}).
+			Where("user_id = ? AND project_id = ?", currentUser.ID, project.ID).
+			Updates(map[string]interface{}{"project_id": nil, "order": gorm.Expr("`order` + ?", offset), "version": utils.BumpTaskVersion()}).Error; err != nil {
+			return err
+		}
+
+		return tx.Unscoped().Delete(&project).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant delete project!"})
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Project successfully deleted!"})
+}
+
+// move a task to a project or back to the inbox with a null projectId
+func UpdateTaskProject(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	if !checkTaskVersion(c, task) {
+		return
+	}
+
+	var input struct {
+		ProjectID *uint `json:"projectId"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.ProjectID != nil && !projectExists(currentUser.ID, *input.ProjectID) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a project!"})
+		return
+	}
+
+	sameAsBefore := (input.ProjectID == nil && task.ProjectID == nil) ||
+		(input.ProjectID != nil && task.ProjectID != nil && *input.ProjectID == *task.ProjectID)
+	order := task.Order
+	if !sameAsBefore {
+		order = nextOrder(initializers.DB, currentUser.ID, input.ProjectID)
+	}
+
+	if !saveTaskFields(c, &task, map[string]interface{}{"project_id": input.ProjectID, "order": order}, "Cant move task!") {
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	setTaskETag(c, task)
+
+	c.JSON(http.StatusOK, gin.H{"data": task})
+}
@@ -0,0 +1,315 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"slices"
+	"strconv"
+)
+
+// normalized and deduplicated tag names, false when a name or the count is invalid
+func normalizeTags(names []string) ([]string, bool) {
+	var normalized []string
+	for _, name := range names {
+		name = utils.NormalizeTag(name)
+		if !utils.IsValidTag(name) {
+			return nil, false
+		}
+		if !slices.Contains(normalized, name) {
+			normalized = append(normalized, name)
+		}
+	}
+
+	return normalized, len(normalized) <= utils.MaxTaskTags
+}
+
+// tags of the user with the given names, missing ones are created
+func resolveTags(tx *gorm.DB, userID uint, names []string) ([]models.Tag, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+
+	tags := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tags = append(tags, models.Tag{UserID: userID, Name: name})
+	}
+	if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	//ids of tags that already existed are not returned by the insert
+	tags = nil
+	err := tx.Where("user_id = ? AND name IN ?", userID, names).Find(&tags).Error
+	return tags, err
+}
+
+// ids of the user tasks carrying a tag
+func taggedTasks(userID uint, tag string) *gorm.DB {
+	return initializers.DB.Table("task_tags").
+		Select("task_tags.task_id").
+		Joins("JOIN tags ON tags.id = task_tags.tag_id").
+		Where("tags.user_id = ? AND tags.name = ?", userID, tag)
+}
+
+// bump the version and log a change of every task carrying the tag, its name is part of the task payload
+func touchTaggedTasks(tx *gorm.DB, tagID uint) error {
+	tagged := tx.Table("task_tags").Select("task_id").Where("tag_id = ?", tagID)
+	tasks := tx.Model(&models.TasksModel{}).Where("id IN (?)", tagged).Session(&gorm.Session{})
+	if err := tasks.Update("version", utils.BumpTaskVersion()).Error; err != nil {
+		return err
+	}
+	return utils.RecordTaskChanges(tx, utils.TaskChangeUpsert, tasks)
+}
+
+// find a tag of the user from the url id, responds with an error when missing
+func findTag(c *gin.Context, userID uint) (models.Tag, bool) {
+	var tag models.Tag
+
+	tagID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong tag id!"})
+		return tag, false
+	}
+
+	if err := initializers.DB.Where("id = ? AND user_id = ?", tagID, userID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a tag!"})
+		return tag, false
+	}
+
+	return tag, true
+}
+
+// move all task links of source to target and drop source
+func mergeTags(tx *gorm.DB, source models.Tag, target models.Tag) error {
+	if err := touchTaggedTasks(tx, source.ID); err != nil {
+		return err
+	}
+
+	//tasks having both tags keep a single link
+	if err := tx.Exec(
+		"DELETE FROM task_tags WHERE tag_id = ? AND task_id IN (SELECT task_id FROM (SELECT task_id FROM task_tags WHERE tag_id = ?) AS tagged)",
+		source.ID, target.ID,
+	).Error; err != nil {
+		return err
+	}
+
+	if err := tx.Exec("UPDATE task_tags SET tag_id = ? WHERE tag_id = ?", target.ID, source.ID).Error; err != nil {
+		return err
+	}
+
+	return tx.Unscoped().Delete(&source).Error
+}
+
+func GetTags(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var tags []struct {
+		models.Tag
+		TaskCount int `json:"taskCount"`
+	}
+	err := initializers.DB.Model(&models.Tag{}).
+		Select("tags.*, COUNT(tasks_models.id) AS task_count").
+		Joins("LEFT JOIN task_tags ON task_tags.tag_id = tags.id").
+		Joins("LEFT JOIN tasks_models ON tasks_models.id = task_tags.task_id AND tasks_models.deleted_at IS NULL").
+		Where("tags.user_id = ?", currentUser.ID).
+		Group("tags.id").
+		Order("tags.name asc").
+		Scan(&tags).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch tags!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": tags})
+}
+
+// replace the tags of a task
+func UpdateTaskTags(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	if !checkTaskVersion(c, task) {
+		return
+	}
+
+	var input struct {
+		Tags []string `json:"tags"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	names, ok := normalizeTags(input.Tags)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"updateTagsError": "Task can have at most 10 tags of 1 to 30 characters!"})
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		tags, err := resolveTags(tx, currentUser.ID, names)
+		if err != nil {
+			return err
+		}
+		if err := tx.Model(&task).Association("Tags").Replace(tags); err != nil {
+			return err
+		}
+
+		//tags live in the join table, the version still moves so other clients see the change
+		if err := utils.UpdateTaskFields(tx, &task, map[string]interface{}{}); err != nil {
+			return err
+		}
+		task.Tags = tags
+		return nil
+	})
+	if errors.Is(err, utils.ErrTaskVersionConflict) {
+		taskConflict(c, task.ID)
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant update task tags!"})
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	setTaskETag(c, task)
+
+	c.JSON(http.StatusOK, gin.H{"data": task})
+}
+
+// rename a tag, renaming to an existing name merges both tags
+func RenameTag(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	tag, ok := findTag(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Name string `json:"name" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	name := utils.NormalizeTag(input.Name)
+	if !utils.IsValidTag(name) {
+		c.JSON(http.StatusBadRequest, gin.H{"tagNameError": "Tag must be between 1 and 30 characters without commas!"})
+		return
+	}
+
+	result := tag
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		var existing models.Tag
+		err := tx.Where("user_id = ? AND name = ? AND id <> ?", currentUser.ID, name, tag.ID).Limit(1).Find(&existing).Error
+		if err != nil {
+			return err
+		}
+
+		if existing.ID != 0 {
+			result = existing
+			return mergeTags(tx, tag, existing)
+		}
+
+		result.Name = name
+		if err := touchTaggedTasks(tx, tag.ID); err != nil {
+			return err
+		}
+		return tx.Save(&result).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant rename tag!"})
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": result, "merged": result.ID != tag.ID})
+}
+
+// merge the url tag into the target tag
+func MergeTag(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	source, ok := findTag(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		TargetID uint `json:"targetId" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.TargetID == source.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Cant merge a tag into itself!"})
+		return
+	}
+
+	var target models.Tag
+	if err := initializers.DB.Where("id = ? AND user_id = ?", input.TargetID, currentUser.ID).First(&target).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a tag!"})
+		return
+	}
+
+	if err := initializers.DB.Transaction(func(tx *gorm.DB) error { return mergeTags(tx, source, target) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant merge tags!"})
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": target})
+}
+
+func DeleteTag(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	tag, ok := findTag(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := touchTaggedTasks(tx, tag.ID); err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM task_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant delete tag!"})
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Tag successfully deleted!"})
+}
@@ -0,0 +1,359 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"time"
+)
+
+const maxBatchOperations = 100
+
+// due date change, a null dueAt clears it
+type batchDue struct {
+	DueAt          *time.Time `json:"dueAt"`
+	ReminderOffset *int       `json:"reminderOffset"`
+}
+
+// one operation of a batch, fields not used by the op are ignored
+type batchOperation struct {
+	Op          string     `json:"op"` //create, update, complete, delete or move
+	LocalID     uint       `json:"localId"`
+	Version     *uint      `json:"version"` //version the op was based on, checked like If-Match
+	Task        *taskInput `json:"task"`    //create
+	Title       *string    `json:"title"`
+	Description *string    `json:"description"`
+	Priority    *string    `json:"priority"`
+	Due         *batchDue  `json:"due"`
+	Tags        []string   `json:"tags"` //replaces the tags when sent
+	Recurrence  *string    `json:"recurrence"`
+	Completed   *bool      `json:"completed"` //complete, defaults to true
+	ProjectID   *uint      `json:"projectId"` //move, null for the inbox
+	Order       *int       `json:"order"`     //move, defaults to the end of the project
+}
+
+// result of one applied operation
+type batchResult struct {
+	Index   int                `json:"index"`
+	Op      string             `json:"op"`
+	LocalID uint               `json:"localId"`
+	Data    *models.TasksModel `json:"data,omitempty"`
+	Next    *models.TasksModel `json:"next,omitempty"` //spawned occurrence of a recurring task
+}
+
+// failed operation, rolls back the whole batch
+type batchError struct {
+	status int
+	body   gin.H
+}
+
+func (e *batchError) Error() string {
+	return fmt.Sprint(e.body)
+}
+
+// side effects run once the batch is committed
+type batchEffects struct {
+	completed int
+	cleared   int
+}
+
+// activity and achievements of committed ops
+func (effects batchEffects) apply(userID uint) {
+	if effects.completed == 0 && effects.cleared == 0 {
+		return
+	}
+
+	go func() {
+		for i := 0; i < effects.completed; i++ {
+			utils.RecordActivity(userID, utils.ActivityTaskCompleted, time.Now())
+		}
+		if effects.completed > 0 {
+			utils.EmitAchievementEvent(userID, utils.EventTaskCompleted)
+			utils.EmitAchievementEvent(userID, utils.EventStreakUpdated)
+		}
+		utils.RecordTasksCleared(userID, effects.cleared)
+	}()
+}
+
+// conflicting op, carries the current server copy of the task
+func batchConflict(taskID uint) error {
+	var current models.TasksModel
+	if err := initializers.DB.Preload("Tags").First(&current, taskID).Error; err != nil {
+		return &batchError{http.StatusNotFound, gin.H{"error": "Cant find a task!"}}
+	}
+	return &batchError{http.StatusConflict, gin.H{"conflictError": "Task was changed by another client!", "data": current}}
+}
+
+// task of the op inside the batch transaction, checked against the version the op was based on
+func batchTask(tx *gorm.DB, userID uint, op batchOperation) (models.TasksModel, error) {
+	var task models.TasksModel
+	err := tx.Where("local_id = ? AND user_id = ?", op.LocalID, userID).First(&task).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return task, &batchError{http.StatusNotFound, gin.H{"error": "Cant find a task!"}}
+	}
+	if err == nil && op.Version != nil && *op.Version != task.Version {
+		return task, batchConflict(task.ID)
+	}
+	return task, err
+}
+
+// versioned field update inside the batch transaction
+func batchSaveFields(tx *gorm.DB, task *models.TasksModel, fields map[string]interface{}) error {
+	err := utils.UpdateTaskFields(tx, task, fields)
+	if errors.Is(err, utils.ErrTaskVersionConflict) {
+		return batchConflict(task.ID)
+	}
+	return err
+}
+
+func batchCreate(tx *gorm.DB, currentUser models.User, op batchOperation) (*models.TasksModel, error) {
+	if op.Task == nil {
+		return nil, &batchError{http.StatusBadRequest, gin.H{"error": "Create needs a task!"}}
+	}
+
+	task, tagNames, status, errorBody := newTaskFromInput(currentUser, *op.Task)
+	if errorBody != nil {
+		return nil, &batchError{status, errorBody}
+	}
+
+	if err := insertTask(tx, &task, tagNames); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+func batchUpdate(tx *gorm.DB, currentUser models.User, op batchOperation) (*models.TasksModel, error) {
+	task, err := batchTask(tx, currentUser.ID, op)
+	if err != nil {
+		return nil, err
+	}
+
+	//only the sent fields are written
+	fields := map[string]interface{}{}
+
+	if op.Title != nil {
+		if !utils.IsValidTitle(*op.Title) {
+			return nil, &batchError{http.StatusBadRequest, gin.H{"updateTitleError": "Title must be between 2 and 95 characters!"}}
+		}
+		fields["title"] = *op.Title
+	}
+
+	if op.Description != nil {
+		if !utils.IsValidDescription(*op.Description) {
+			return nil, &batchError{http.StatusBadRequest, gin.H{"updateDescriptionError": "Description must be  between 2 and 870 characters!"}}
+		}
+		fields["description"] = *op.Description
+	}
+
+	if op.Priority != nil {
+		priority, ok := utils.ParsePriority(*op.Priority)
+		if !ok {
+			return nil, &batchError{http.StatusBadRequest, gin.H{"updatePriorityError": "Priority must be none, low, medium, high or urgent!"}}
+		}
+		fields["priority"] = priority
+	}
+
+	//the recurrence anchor uses the new due date when both change
+	anchorTask := task
+	if op.Due != nil {
+		if !utils.IsValidReminderOffset(op.Due.ReminderOffset, op.Due.DueAt) {
+			return nil, &batchError{http.StatusBadRequest, gin.H{"updateDueError": "Reminder needs a due date and must be at most 7 days before it!"}}
+		}
+		utils.SetTaskDue(&anchorTask, op.Due.DueAt, op.Due.ReminderOffset)
+		for column, value := range dueFields(anchorTask) {
+			fields[column] = value
+		}
+		if task.Recurrence != "" && anchorTask.DueAt != nil {
+			fields["recurrence"] = utils.PinRecurrenceTime(task.Recurrence, anchorTask.DueAt.In(utils.UserLocation(currentUser)))
+		}
+	}
+
+	if op.Recurrence != nil {
+		recurrence := ""
+		if *op.Recurrence != "" {
+			anchor := time.Now()
+			if anchorTask.DueAt != nil {
+				anchor = *anchorTask.DueAt
+			}
+
+			if recurrence, err = utils.NormalizeRecurrence(*op.Recurrence, anchor.In(utils.UserLocation(currentUser))); err != nil {
+				return nil, &batchError{http.StatusBadRequest, gin.H{"updateRecurrenceError": err.Error()}}
+			}
+		}
+		fields["recurrence"] = recurrence
+	}
+
+	if err := batchSaveFields(tx, &task, fields); err != nil {
+		return nil, err
+	}
+
+	if op.Tags != nil {
+		names, ok := normalizeTags(op.Tags)
+		if !ok {
+			return nil, &batchError{http.StatusBadRequest, gin.H{"updateTagsError": "Task can have at most 10 tags of 1 to 30 characters!"}}
+		}
+
+		tags, err := resolveTags(tx, currentUser.ID, names)
+		if err != nil {
+			return nil, err
+		}
+		task.Tags = tags
+		if err := tx.Model(&task).Association("Tags").Replace(tags); err != nil {
+			return nil, err
+		}
+	}
+
+	return &task, nil
+}
+
+func batchComplete(tx *gorm.DB, currentUser models.User, op batchOperation, effects *batchEffects) (*models.TasksModel, *models.TasksModel, error) {
+	task, err := batchTask(tx, currentUser.ID, op)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	completed := op.Completed == nil || *op.Completed
+	justCompleted := completed && !task.Completed
+
+	if err := batchSaveFields(tx, &task, map[string]interface{}{"completed": completed}); err != nil {
+		return nil, nil, err
+	}
+
+	var next *models.TasksModel
+	if justCompleted {
+		effects.completed++
+		if task.Recurrence != "" {
+			if next, err = spawnNextOccurrence(tx, &task, utils.UserLocation(currentUser)); err != nil {
+				return nil, nil, err
+			}
+		}
+	}
+
+	return &task, next, nil
+}
+
+func batchDelete(tx *gorm.DB, currentUser models.User, op batchOperation, effects *batchEffects) error {
+	task, err := batchTask(tx, currentUser.ID, op)
+	if err != nil {
+		return err
+	}
+
+	err = utils.DeleteTaskVersion(tx, &task)
+	if errors.Is(err, utils.ErrTaskVersionConflict) {
+		return batchConflict(task.ID)
+	}
+	if err != nil {
+		return err
+	}
+
+	if task.Completed {
+		effects.cleared++
+	}
+	return nil
+}
+
+func batchMove(tx *gorm.DB, currentUser models.User, op batchOperation) (*models.TasksModel, error) {
+	task, err := batchTask(tx, currentUser.ID, op)
+	if err != nil {
+		return nil, err
+	}
+
+	if op.ProjectID != nil && !projectExists(currentUser.ID, *op.ProjectID) {
+		return nil, &batchError{http.StatusNotFound, gin.H{"error": "Cant find a project!"}}
+	}
+
+	order := nextOrder(tx, currentUser.ID, op.ProjectID)
+	if op.Order != nil {
+		order = *op.Order
+	}
+
+	if err := batchSaveFields(tx, &task, map[string]interface{}{"project_id": op.ProjectID, "order": order}); err != nil {
+		return nil, err
+	}
+	return &task, nil
+}
+
+// apply one operation inside tx, returns the changed task and a spawned occurrence
+func applyBatchOperation(tx *gorm.DB, currentUser models.User, op batchOperation, effects *batchEffects) (*models.TasksModel, *models.TasksModel, error) {
+	switch op.Op {
+	case "create":
+		task, err := batchCreate(tx, currentUser, op)
+		return task, nil, err
+	case "update":
+		task, err := batchUpdate(tx, currentUser, op)
+		return task, nil, err
+	case "complete":
+		return batchComplete(tx, currentUser, op, effects)
+	case "delete":
+		return nil, nil, batchDelete(tx, currentUser, op, effects)
+	case "move":
+		task, err := batchMove(tx, currentUser, op)
+		return task, nil, err
+	}
+	return nil, nil, &batchError{http.StatusBadRequest, gin.H{"error": "Operation must be create, update, complete, delete or move!"}}
+}
+
+// run a list of task operations in one transaction, all of them apply or none
+func BatchTasks(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var input struct {
+		Operations []batchOperation `json:"operations" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(input.Operations) == 0 || len(input.Operations) > maxBatchOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Batch must have between 1 and 100 operations!"})
+		return
+	}
+
+	var effects batchEffects
+	results := make([]batchResult, 0, len(input.Operations))
+	failed := -1
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		for i, op := range input.Operations {
+			result := batchResult{Index: i, Op: op.Op, LocalID: op.LocalID}
+
+			var err error
+			result.Data, result.Next, err = applyBatchOperation(tx, currentUser, op, &effects)
+			if err != nil {
+				failed = i
+				return err
+			}
+
+			if result.Data != nil {
+				result.LocalID = result.Data.LocalID
+			}
+			results = append(results, result)
+		}
+		return nil
+	})
+
+	if err != nil {
+		var opErr *batchError
+		if errors.As(err, &opErr) {
+			c.JSON(opErr.status, gin.H{"failedIndex": failed, "failed": opErr.body})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant apply batch!", "failedIndex": failed})
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	effects.apply(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": results})
+}
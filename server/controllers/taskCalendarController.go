@@ -0,0 +1,115 @@
+package controllers
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"net/http"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+)
+
+// random url safe feed token
+func generateCalendarToken() (string, error) {
+	buffer := make([]byte, 32)
+	if _, err := rand.Read(buffer); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buffer), nil
+}
+
+// feed url relative to the api host
+func calendarFeedPath(token string) string {
+	return "/tasks/ical/" + token
+}
+
+// feed token of the user, false when the feed is not enabled yet
+func GetCalendarFeed(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var feed models.CalendarFeed
+	err := initializers.DB.Where("user_id = ?", currentUser.ID).First(&feed).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusOK, gin.H{"enabled": false})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch calendar feed!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "token": feed.Token, "path": calendarFeedPath(feed.Token)})
+}
+
+// create the feed token or replace it, the old url stops working
+func RotateCalendarToken(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	token, err := generateCalendarToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant generate calendar token!"})
+		return
+	}
+
+	feed := models.CalendarFeed{UserID: currentUser.ID, Token: token}
+	if err := initializers.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"token", "updated_at"}),
+	}).Create(&feed).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant rotate calendar token!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": true, "token": token, "path": calendarFeedPath(token)})
+}
+
+func DisableCalendarFeed(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	if err := initializers.DB.Unscoped().Where("user_id = ?", currentUser.ID).Delete(&models.CalendarFeed{}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant disable calendar feed!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"enabled": false})
+}
+
+// public VTODO feed, the token in the url is the only credential
+func TaskCalendar(c *gin.Context) {
+	var feed models.CalendarFeed
+	if err := initializers.DB.Where("token = ?", c.Param("token")).First(&feed).Error; err != nil {
+		c.String(http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	var user models.User
+	if err := initializers.DB.Select("id", "username", "timezone").First(&user, feed.UserID).Error; err != nil {
+		c.String(http.StatusNotFound, "Calendar not found")
+		return
+	}
+
+	var tasks []models.TasksModel
+	if err := initializers.DB.Where("user_id = ?", feed.UserID).
+		Preload("Tags").Preload("Items").
+		Order("`order` asc").
+		Find(&tasks).Error; err != nil {
+		c.String(http.StatusInternalServerError, "Cant load tasks")
+		return
+	}
+
+	name := "Tasks"
+	if user.Username != "" {
+		name = fmt.Sprintf("%s tasks", user.Username)
+	}
+
+	c.Header("Cache-Control", "private, max-age=300")
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", utils.BuildTaskCalendar(tasks, name, c.Request.Host, utils.UserLocation(user)))
+}
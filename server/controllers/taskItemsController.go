@@ -0,0 +1,276 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"strconv"
+)
+
+const maxTaskItems = 50
+
+// find a task of the user from the url local id, responds with an error when missing
+func findTask(c *gin.Context, userID uint) (models.TasksModel, bool) {
+	var task models.TasksModel
+
+	localTaskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong task id!"})
+		return task, false
+	}
+
+	if err := initializers.DB.Where("local_id = ? AND user_id = ?", localTaskID, userID).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a task!"})
+		return task, false
+	}
+
+	return task, true
+}
+
+// find an item of the task from the url item id, responds with an error when missing
+func findTaskItem(c *gin.Context, task models.TasksModel) (models.TaskItem, bool) {
+	var item models.TaskItem
+
+	itemID, err := strconv.Atoi(c.Param("itemId"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong item id!"})
+		return item, false
+	}
+
+	if err := initializers.DB.Where("id = ? AND task_id = ?", itemID, task.ID).First(&item).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a checklist item!"})
+		return item, false
+	}
+
+	return item, true
+}
+
+// change the checklist and bump the version of its task in one transaction,
+// responds on a conflict or failure
+func saveTaskItems(c *gin.Context, task *models.TasksModel, change func(tx *gorm.DB) error, failMessage string) bool {
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := change(tx); err != nil {
+			return err
+		}
+		return utils.UpdateTaskFields(tx, task, map[string]interface{}{})
+	})
+	if errors.Is(err, utils.ErrTaskVersionConflict) {
+		taskConflict(c, task.ID)
+		return false
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failMessage})
+		return false
+	}
+
+	setTaskETag(c, *task)
+	return true
+}
+
+// checklist items in order
+func orderedItems(db *gorm.DB) *gorm.DB {
+	return db.Order("`order` asc, id asc")
+}
+
+// checklist of a task with its progress
+func taskItemsResponse(taskID uint) (gin.H, error) {
+	var items []models.TaskItem
+	if err := orderedItems(initializers.DB).Where("task_id = ?", taskID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+
+	return gin.H{"data": items, "progress": utils.TaskProgress(items)}, nil
+}
+
+func GetTaskItems(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	response, err := taskItemsResponse(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch checklist!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+func CreateTaskItem(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	var input struct {
+		Title string `json:"title" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !utils.IsValidItemTitle(input.Title) {
+		c.JSON(http.StatusBadRequest, gin.H{"createItemError": "Item title must be between 1 and 200 characters!"})
+		return
+	}
+
+	var count int64
+	initializers.DB.Model(&models.TaskItem{}).Where("task_id = ?", task.ID).Count(&count)
+	if count >= maxTaskItems {
+		c.JSON(http.StatusBadRequest, gin.H{"createItemError": "Task can have at most 50 checklist items!"})
+		return
+	}
+
+	var maxOrder int
+	initializers.DB.Model(&models.TaskItem{}).
+		Where("task_id = ?", task.ID).
+		Select("COALESCE(MAX(`order`), 0)").
+		Scan(&maxOrder)
+
+	item := models.TaskItem{
+		TaskID: task.ID,
+		UserID: currentUser.ID,
+		Title:  input.Title,
+		Order:  maxOrder + 1,
+	}
+
+	if !saveTaskItems(c, &task, func(tx *gorm.DB) error {
+		return tx.Create(&item).Error
+	}, "Cant create checklist item!") {
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": item})
+}
+
+func UpdateTaskItem(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	item, ok := findTaskItem(c, task)
+	if !ok {
+		return
+	}
+
+	//only the sent fields change
+	var input struct {
+		Title     *string `json:"title"`
+		Completed *bool   `json:"completed"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if input.Title != nil {
+		if !utils.IsValidItemTitle(*input.Title) {
+			c.JSON(http.StatusBadRequest, gin.H{"updateItemError": "Item title must be between 1 and 200 characters!"})
+			return
+		}
+		item.Title = *input.Title
+	}
+
+	if input.Completed != nil {
+		item.Completed = *input.Completed
+	}
+
+	if !saveTaskItems(c, &task, func(tx *gorm.DB) error {
+		return tx.Save(&item).Error
+	}, "Cant update checklist item!") {
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": item})
+}
+
+func DeleteTaskItem(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	item, ok := findTaskItem(c, task)
+	if !ok {
+		return
+	}
+
+	if !saveTaskItems(c, &task, func(tx *gorm.DB) error {
+		return tx.Unscoped().Delete(&item).Error
+	}, "Cant delete checklist item!") {
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Checklist item successfully deleted!"})
+}
+
+func UpdateTaskItemsOrder(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	var input []struct {
+		ID    uint `json:"id" binding:"required"`
+		Order int  `json:"order" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !saveTaskItems(c, &task, func(tx *gorm.DB) error {
+		for _, entry := range input {
+			if err := tx.Model(&models.TaskItem{}).
+				Where("id = ? AND task_id = ?", entry.ID, task.ID).
+				Update("order", entry.Order).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	}, "Cannot update checklist order!") {
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	response, err := taskItemsResponse(task.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch checklist!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,108 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"strings"
+)
+
+const (
+	maxSearchQueryLength = 200
+	defaultSearchLimit   = 20
+	maxSearchLimit       = 100
+	maxSearchCandidates  = 1000 //tasks ranked in process per search
+)
+
+func SearchTasks(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" || len(q) > maxSearchQueryLength {
+		c.JSON(http.StatusBadRequest, gin.H{"searchError": "Search query must be between 1 and 200 characters!"})
+		return
+	}
+
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", strconv.Itoa(defaultSearchLimit)))
+	if err != nil || limit < 1 || limit > maxSearchLimit {
+		c.JSON(http.StatusBadRequest, gin.H{"searchError": "Limit must be between 1 and 100!"})
+		return
+	}
+
+	query := utils.ParseSearchQuery(q)
+
+	//operators narrow the candidates in sql, the text is ranked in process
+	candidates := initializers.DB.Where("user_id = ?", currentUser.ID)
+	if query.Done != nil {
+		candidates = candidates.Where("completed = ?", *query.Done)
+	}
+	if query.Priority != nil {
+		candidates = candidates.Where("priority = ?", *query.Priority)
+	}
+	for _, tag := range query.Tags {
+		candidates = candidates.Where("id IN (?)", taggedTasks(currentUser.ID, tag))
+	}
+	switch {
+	case strings.EqualFold(query.Project, "none"):
+		candidates = candidates.Where("project_id IS NULL")
+	case query.Project != "":
+		projects := initializers.DB.Model(&models.Project{}).Select("id").
+			Where("user_id = ? AND LOWER(name) = ?", currentUser.ID, strings.ToLower(query.Project))
+		candidates = candidates.Where("project_id IN (?)", projects)
+	}
+
+	//every term and phrase word is a prefix of some word of the task, so a substring match
+	//keeps all hits and drops most of the rest before ranking. words hold only letters and digits,
+	//so they need no LIKE escaping
+	words := append([]string{}, query.Terms...)
+	for _, phrase := range query.Phrases {
+		words = append(words, phrase...)
+	}
+	for _, word := range words {
+		pattern := "%" + word + "%"
+		candidates = candidates.Where("(LOWER(title) LIKE ? OR LOWER(description) LIKE ?)", pattern, pattern)
+	}
+	candidates = candidates.Session(&gorm.Session{})
+
+	//only operators, keep the manual order and load just the page
+	if !query.HasText() {
+		var total int64
+		if err := candidates.Model(&models.TasksModel{}).Count(&total).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant search tasks!"})
+			return
+		}
+
+		var tasks []models.TasksModel
+		if err := candidates.Preload("Tags").Order("`order` asc").Limit(limit).Find(&tasks).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant search tasks!"})
+			return
+		}
+
+		hits := make([]utils.SearchHit, 0, len(tasks))
+		for _, task := range tasks {
+			hits = append(hits, utils.SearchHit{Task: task, Highlights: map[string]string{}})
+		}
+		c.JSON(http.StatusOK, gin.H{"data": hits, "total": total})
+		return
+	}
+
+	//very common words can still match a lot, only the first candidates in manual order are ranked
+	var tasks []models.TasksModel
+	if err := candidates.Preload("Tags").Order("`order` asc").Limit(maxSearchCandidates).Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant search tasks!"})
+		return
+	}
+
+	hits := utils.SearchTasks(tasks, query)
+	total := len(hits)
+	if total > limit {
+		hits = hits[:limit]
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": hits, "total": total})
+}
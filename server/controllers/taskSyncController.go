@@ -0,0 +1,342 @@
+package controllers
+
+import (
+	"errors"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"slices"
+	"time"
+)
+
+const (
+	maxSyncOperations = 500
+	maxSyncChanges    = 500
+	maxSyncOpIDLength = 64
+)
+
+// statuses of a synced client operation
+const (
+	syncApplied   = "applied"
+	syncDuplicate = "duplicate" //already applied by an earlier sync
+	syncConflict  = "conflict"  //the server copy won, data holds it
+	syncRejected  = "rejected"  //invalid, retrying will not help
+	syncFailed    = "failed"    //server error, retry later
+)
+
+// batch operation made offline, version is the task version the client edited
+type syncOperation struct {
+	batchOperation
+	OpID       string    `json:"opId"` //unique per client operation, makes retries safe
+	ClientTime time.Time `json:"clientTime"`
+	ClientID   string    `json:"clientId"` //temp id of a task created offline, names it instead of localId on later ops
+}
+
+type syncResult struct {
+	OpID     string             `json:"opId"`
+	Status   string             `json:"status"`
+	ClientID string             `json:"clientId,omitempty"`
+	LocalID  uint               `json:"localId,omitempty"` //server id of the task, maps the clientId of a create
+	Data     *models.TasksModel `json:"data,omitempty"`
+	Next     *models.TasksModel `json:"next,omitempty"`
+	Error    gin.H              `json:"error,omitempty"`
+}
+
+// current server state of a task changed after the cursor
+type syncChange struct {
+	LocalID uint               `json:"localId"`
+	Deleted bool               `json:"deleted"`
+	Task    *models.TasksModel `json:"task,omitempty"`
+}
+
+// an op based on the current version always applies, otherwise the later change wins
+// and ties go to the server, so every replica resolves the same way
+func syncClientWins(op syncOperation, task models.TasksModel) bool {
+	if op.Version != nil && *op.Version == task.Version {
+		return true
+	}
+	return op.ClientTime.After(task.UpdatedAt)
+}
+
+// task with tags for a sync result, nil when it is gone
+func syncTask(userID uint, localID uint) *models.TasksModel {
+	var task models.TasksModel
+	if err := initializers.DB.Preload("Tags").Where("local_id = ? AND user_id = ?", localID, userID).Limit(1).Find(&task).Error; err != nil || task.ID == 0 {
+		return nil
+	}
+	return &task
+}
+
+// apply one client operation in its own transaction
+func applySyncOperation(currentUser models.User, op syncOperation, effects *batchEffects) syncResult {
+	result := syncResult{OpID: op.OpID, LocalID: op.LocalID}
+
+	//a retried op that already changed a task is not applied twice
+	var done models.TaskChange
+	if err := initializers.DB.Where("user_id = ? AND op_id = ?", currentUser.ID, op.OpID).Limit(1).Find(&done).Error; err != nil {
+		result.Status, result.Error = syncFailed, gin.H{"error": "Cant apply operation!"}
+		return result
+	}
+	if done.ID != 0 {
+		result.Status, result.LocalID = syncDuplicate, done.LocalID
+		result.Data = syncTask(currentUser.ID, done.LocalID)
+		return result
+	}
+
+	var opEffects batchEffects
+	result.Status = syncApplied
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		tx = utils.WithSyncOperation(tx, op.OpID)
+
+		if op.Op != "create" {
+			var task models.TasksModel
+			err := tx.Where("local_id = ? AND user_id = ?", op.LocalID, currentUser.ID).First(&task).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				if op.Op == "delete" {
+					//already deleted, nothing left to do
+					return nil
+				}
+				return &batchError{http.StatusNotFound, gin.H{"error": "Cant find a task!"}}
+			}
+			if err != nil {
+				return err
+			}
+
+			if !syncClientWins(op, task) {
+				return batchConflict(task.ID)
+			}
+			op.Version = &task.Version
+		}
+
+		var err error
+		result.Data, result.Next, err = applyBatchOperation(tx, currentUser, op.batchOperation, &opEffects)
+		return err
+	})
+
+	var opErr *batchError
+	switch {
+	case err == nil:
+		effects.completed += opEffects.completed
+		effects.cleared += opEffects.cleared
+		if result.Data != nil {
+			result.LocalID = result.Data.LocalID
+		}
+	case errors.As(err, &opErr) && opErr.status == http.StatusConflict:
+		result.Status, result.Data, result.Next = syncConflict, nil, nil
+		if current, ok := opErr.body["data"].(models.TasksModel); ok {
+			result.Data = &current
+		}
+	case errors.As(err, &opErr):
+		result.Status, result.Data, result.Next, result.Error = syncRejected, nil, nil, opErr.body
+	default:
+		result.Status, result.Data, result.Next, result.Error = syncFailed, nil, nil, gin.H{"error": "Cant apply operation!"}
+	}
+
+	return result
+}
+
+// tasks with tags, checklist and progress by id, trashed ones included
+func syncTasksByID(ids []uint) (map[uint]models.TasksModel, error) {
+	var tasks []models.TasksModel
+	if err := initializers.DB.Unscoped().
+		Where("id IN ?", ids).
+		Preload("Tags").
+		Preload("Items", orderedItems).
+		Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]models.TasksModel, len(tasks))
+	for _, task := range tasks {
+		task.Progress = utils.TaskProgress(task.Items)
+		byID[task.ID] = task
+	}
+	return byID, nil
+}
+
+// latest state of every task changed after the cursor, oldest change first
+func syncChangesSince(userID uint, cursor uint) ([]syncChange, uint, bool, error) {
+	var latest []struct {
+		TaskID    uint
+		LocalID   uint
+		ChangeSeq uint
+	}
+	if err := initializers.DB.Model(&models.TaskChange{}).
+		Select("task_id, MAX(local_id) AS local_id, MAX(seq) AS change_seq").
+		Where("user_id = ? AND seq > ?", userID, cursor).
+		Group("task_id").
+		Order("change_seq asc").
+		Limit(maxSyncChanges + 1).
+		Scan(&latest).Error; err != nil {
+		return nil, cursor, false, err
+	}
+
+	hasMore := len(latest) > maxSyncChanges
+	if hasMore {
+		latest = latest[:maxSyncChanges]
+	}
+	if len(latest) == 0 {
+		return []syncChange{}, cursor, false, nil
+	}
+
+	ids := make([]uint, 0, len(latest))
+	for _, entry := range latest {
+		ids = append(ids, entry.TaskID)
+	}
+	tasks, err := syncTasksByID(ids)
+	if err != nil {
+		return nil, cursor, false, err
+	}
+
+	changes := make([]syncChange, 0, len(latest))
+	for _, entry := range latest {
+		change := syncChange{LocalID: entry.LocalID, Deleted: true}
+		if task, ok := tasks[entry.TaskID]; ok && !task.DeletedAt.Valid {
+			change.Deleted = false
+			change.Task = &task
+		}
+		changes = append(changes, change)
+	}
+
+	return changes, latest[len(latest)-1].ChangeSeq, hasMore, nil
+}
+
+// every live task of the user, for clients without a usable cursor.
+// the cursor is numbered before loading, changes made while loading are sent again next time
+func syncSnapshot(userID uint, cursor uint) ([]syncChange, uint, error) {
+	var ids []uint
+	if err := initializers.DB.Model(&models.TasksModel{}).Where("user_id = ?", userID).Order("`order` asc").Pluck("id", &ids).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tasks, err := syncTasksByID(ids)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	changes := make([]syncChange, 0, len(ids))
+	for _, id := range ids {
+		if task, ok := tasks[id]; ok {
+			changes = append(changes, syncChange{LocalID: task.LocalID, Task: &task})
+		}
+	}
+	return changes, cursor, nil
+}
+
+// apply offline client operations and return the server changes since the client cursor
+func SyncTasks(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var input struct {
+		Cursor     uint            `json:"cursor"` //sequence of the last seen change, 0 asks for a full snapshot
+		Operations []syncOperation `json:"operations"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if len(input.Operations) > maxSyncOperations {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sync can have at most 500 operations!"})
+		return
+	}
+
+	//clocks ahead of the server would win every conflict
+	now := time.Now()
+	clientIDs := make(map[string]bool)
+	for i := range input.Operations {
+		op := &input.Operations[i]
+		if op.OpID == "" || len(op.OpID) > maxSyncOpIDLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Every operation needs an opId of at most 64 characters!"})
+			return
+		}
+		if len(op.ClientID) > maxSyncOpIDLength {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "ClientId must be at most 64 characters!"})
+			return
+		}
+		if op.Op == "create" && op.ClientID != "" {
+			if clientIDs[op.ClientID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Every create needs its own clientId!"})
+				return
+			}
+			clientIDs[op.ClientID] = true
+		}
+		if op.ClientTime.After(now) {
+			op.ClientTime = now
+		}
+	}
+
+	//ops from several offline queues apply in the order they were made
+	slices.SortStableFunc(input.Operations, func(a, b syncOperation) int {
+		return a.ClientTime.Compare(b.ClientTime)
+	})
+
+	var effects batchEffects
+	results := make([]syncResult, 0, len(input.Operations))
+	applied := false
+	//local ids of tasks created in this sync by their clientId
+	created := make(map[string]uint)
+	for _, op := range input.Operations {
+		if op.Op != "create" && op.ClientID != "" {
+			localID, ok := created[op.ClientID]
+			if !ok {
+				results = append(results, syncResult{OpID: op.OpID, ClientID: op.ClientID, Status: syncRejected,
+					Error: gin.H{"error": "Cant find a task created with this clientId!"}})
+				continue
+			}
+			op.LocalID = localID
+		}
+
+		result := applySyncOperation(currentUser, op, &effects)
+		result.ClientID = op.ClientID
+		if op.Op == "create" && op.ClientID != "" && result.LocalID != 0 &&
+			(result.Status == syncApplied || result.Status == syncDuplicate) {
+			created[op.ClientID] = result.LocalID
+		}
+
+		applied = applied || result.Status == syncApplied
+		results = append(results, result)
+	}
+
+	if applied {
+		cache.InvalidateUserTaskCaches(currentUser.ID)
+		effects.apply(currentUser.ID)
+	}
+
+	latest, err := utils.SequenceTaskChanges(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant load task changes!", "results": results})
+		return
+	}
+
+	available, err := utils.TaskChangeCursorAvailable(currentUser.ID, input.Cursor, latest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant load task changes!", "results": results})
+		return
+	}
+
+	if !available {
+		changes, cursor, err := syncSnapshot(currentUser.ID, latest)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant load task changes!", "results": results})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"results": results, "changes": changes, "cursor": cursor, "hasMore": false, "reset": true})
+		return
+	}
+
+	changes, cursor, hasMore, err := syncChangesSince(currentUser.ID, input.Cursor)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant load task changes!", "results": results})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results, "changes": changes, "cursor": cursor, "hasMore": hasMore, "reset": false})
+}
@@ -0,0 +1,314 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"io"
+	"net/http"
+	"path/filepath"
+	"server/cache"
+	"server/importers"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"slices"
+	"strings"
+	"time"
+)
+
+const maxImportSize = 2 << 20 //bytes
+
+var errProjectLimit = errors.New("project limit reached")
+
+// failed import row
+type importError struct {
+	Row   int    `json:"row"`
+	Error string `json:"error"`
+}
+
+// validated import row ready to insert
+type importTask struct {
+	task     models.TasksModel
+	tagNames []string
+	project  string
+	order    int
+	row      int
+}
+
+// project of the user with the given name, created when missing
+func projectByName(tx *gorm.DB, userID uint, name string, ids map[string]uint) (uint, error) {
+	if id, ok := ids[name]; ok {
+		return id, nil
+	}
+
+	var project models.Project
+	if err := tx.Where("user_id = ? AND name = ?", userID, name).Limit(1).Find(&project).Error; err != nil {
+		return 0, err
+	}
+
+	if project.ID == 0 {
+		var count int64
+		if err := tx.Model(&models.Project{}).Where("user_id = ?", userID).Count(&count).Error; err != nil {
+			return 0, err
+		}
+		if count >= maxProjects {
+			return 0, errProjectLimit
+		}
+
+		project = models.Project{UserID: userID, Name: name, Order: int(count) + 1}
+		if err := tx.Create(&project).Error; err != nil {
+			return 0, err
+		}
+	}
+
+	ids[name] = project.ID
+	return project.ID, nil
+}
+
+// check an import row the way CreateTask checks a new task, empty descriptions are allowed
+// since checklists and most exported tasks dont have them
+func validateImportRow(userID uint, row utils.ImportRow) (importTask, string) {
+	input := row.Task
+
+	if !utils.IsValidTitle(input.Title) {
+		return importTask{}, "Title must be between 2 and 95 characters!"
+	}
+
+	if input.Description != "" && !utils.IsValidDescription(input.Description) {
+		return importTask{}, "Description must be at most 870 characters!"
+	}
+
+	priority := 0
+	if input.Priority != "" {
+		var ok bool
+		if priority, ok = utils.ParsePriority(input.Priority); !ok {
+			return importTask{}, "Priority must be none, low, medium, high or urgent!"
+		}
+	}
+
+	tagNames, ok := normalizeTags(input.Tags)
+	if !ok {
+		return importTask{}, "Task can have at most 10 tags of 1 to 30 characters!"
+	}
+
+	project := strings.TrimSpace(input.Project)
+	if project != "" && !utils.IsValidProjectName(project) {
+		return importTask{}, "Project name must be between 1 and 50 characters!"
+	}
+
+	task := models.TasksModel{
+		UserID:      userID,
+		Title:       input.Title,
+		Description: input.Description,
+		Completed:   input.Completed,
+		Priority:    priority,
+	}
+	utils.SetTaskDue(&task, input.DueAt, nil)
+
+	return importTask{task: task, tagNames: tagNames, project: project, order: input.Order, row: row.Row}, ""
+}
+
+// read the uploaded file from a multipart file field or the raw body, format comes from the query or the file name
+// (todoist and taskwarrior files need the format query)
+func readImportFile(c *gin.Context) ([]byte, string, error) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxImportSize)
+	format := strings.ToLower(c.Query("format"))
+
+	var reader io.Reader = c.Request.Body
+	if file, header, err := c.Request.FormFile("file"); err == nil {
+		defer file.Close()
+		reader = file
+		if format == "" {
+			format = strings.TrimPrefix(strings.ToLower(filepath.Ext(header.Filename)), ".")
+		}
+	}
+
+	if format == "markdown" {
+		format = utils.TransferMarkdown
+	}
+
+	data, err := io.ReadAll(reader)
+	return data, format, err
+}
+
+// tasks as transfer rows in manual order, grouped by project for the checklist format
+func exportTasksFor(userID uint) ([]utils.TransferTask, error) {
+	var tasks []models.TasksModel
+	if err := initializers.DB.Where("user_id = ?", userID).Preload("Tags").Order("`order` asc").Find(&tasks).Error; err != nil {
+		return nil, err
+	}
+
+	var projects []models.Project
+	if err := initializers.DB.Where("user_id = ?", userID).Order("`order` asc").Find(&projects).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(projects))
+	positions := make(map[uint]int, len(projects))
+	for i, project := range projects {
+		names[project.ID] = project.Name
+		positions[project.ID] = i + 1
+	}
+
+	//inbox first, then projects in their order
+	slices.SortStableFunc(tasks, func(a, b models.TasksModel) int {
+		position := func(task models.TasksModel) int {
+			if task.ProjectID == nil {
+				return 0
+			}
+			return positions[*task.ProjectID]
+		}
+		return position(a) - position(b)
+	})
+
+	exported := make([]utils.TransferTask, 0, len(tasks))
+	for _, task := range tasks {
+		entry := utils.TransferTask{
+			Title:       task.Title,
+			Description: task.Description,
+			Completed:   task.Completed,
+			Order:       task.Order,
+			DueAt:       task.DueAt,
+		}
+		if task.Priority > 0 && task.Priority < len(utils.TaskPriorities) {
+			entry.Priority = utils.TaskPriorities[task.Priority]
+		}
+		if task.ProjectID != nil {
+			entry.Project = names[*task.ProjectID]
+		}
+		for _, tag := range task.Tags {
+			entry.Tags = append(entry.Tags, tag.Name)
+		}
+		exported = append(exported, entry)
+	}
+	return exported, nil
+}
+
+func ExportTasks(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	format := strings.ToLower(c.DefaultQuery("format", utils.TransferJSON))
+	if !utils.IsValidTransferFormat(format) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Format must be json, csv or md!"})
+		return
+	}
+
+	tasks, err := exportTasksFor(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant export tasks!"})
+		return
+	}
+
+	data, contentType, err := utils.ExportTasks(format, tasks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant export tasks!"})
+		return
+	}
+
+	filename := fmt.Sprintf("tasks-%s.%s", time.Now().In(utils.UserLocation(currentUser)).Format(time.DateOnly), format)
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%q", filename))
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// import parsed rows, invalid rows are reported and skipped, valid ones are inserted together
+func importRows(c *gin.Context, currentUser models.User, rows []utils.ImportRow) {
+	errorsList := make([]importError, 0)
+	valid := make([]importTask, 0, len(rows))
+	for _, row := range rows {
+		if row.Error != "" {
+			errorsList = append(errorsList, importError{Row: row.Row, Error: row.Error})
+			continue
+		}
+
+		task, message := validateImportRow(currentUser.ID, row)
+		if message != "" {
+			errorsList = append(errorsList, importError{Row: row.Row, Error: message})
+			continue
+		}
+		valid = append(valid, task)
+	}
+
+	if c.Query("dryRun") == "true" {
+		c.JSON(http.StatusOK, gin.H{"imported": 0, "valid": len(valid), "errors": errorsList})
+		return
+	}
+
+	//appended after the existing tasks keeping the file order
+	slices.SortStableFunc(valid, func(a, b importTask) int { return a.order - b.order })
+
+	//rows whose project cant be created still land in the inbox and are reported
+	var projectErrors []importError
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		projectErrors = nil
+		projectIDs := make(map[string]uint)
+		for i := range valid {
+			entry := &valid[i]
+			if entry.project != "" {
+				projectID, err := projectByName(tx, currentUser.ID, entry.project, projectIDs)
+				switch {
+				case errors.Is(err, errProjectLimit):
+					projectErrors = append(projectErrors, importError{Row: entry.row, Error: fmt.Sprintf("You can have at most %d projects, task was imported into the inbox!", maxProjects)})
+				case err != nil:
+					projectErrors = append(projectErrors, importError{Row: entry.row, Error: "Cant create project, task was imported into the inbox!"})
+				default:
+					entry.task.ProjectID = &projectID
+				}
+			}
+
+			if err := insertTask(tx, &entry.task, entry.tagNames); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant import tasks!", "errors": errorsList})
+		return
+	}
+
+	if len(valid) > 0 {
+		cache.InvalidateUserTaskCaches(currentUser.ID)
+	}
+
+	errorsList = append(errorsList, projectErrors...)
+	slices.SortStableFunc(errorsList, func(a, b importError) int { return a.Row - b.Row })
+
+	localIDs := make([]uint, 0, len(valid))
+	for _, entry := range valid {
+		localIDs = append(localIDs, entry.task.LocalID)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"imported": len(valid), "localIds": localIDs, "errors": errorsList})
+}
+
+func ImportTasks(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	data, format, err := readImportFile(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"importError": "File must be at most 2MB!"})
+		return
+	}
+
+	importer, ok := importers.Get(format)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"importError": "Format must be one of " + strings.Join(importers.Names(), ", ") + "!"})
+		return
+	}
+
+	rows, err := importer.Parse(data)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"importError": err.Error()})
+		return
+	}
+
+	if len(rows) > utils.MaxImportRows {
+		c.JSON(http.StatusBadRequest, gin.H{"importError": fmt.Sprintf("File has more than %d tasks!", utils.MaxImportRows)})
+		return
+	}
+
+	importRows(c, currentUser, rows)
+}
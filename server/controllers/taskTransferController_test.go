@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"server/importers"
+	"server/models"
+	"slices"
+	"testing"
+)
+
+// dry run of importRows on a fixture, returns the valid count and the failed rows
+func dryRunImport(t *testing.T, format string, data []byte) (int, []importError) {
+	t.Helper()
+
+	importer, ok := importers.Get(format)
+	if !ok {
+		t.Fatalf("importer %q is not registered", format)
+	}
+	rows, err := importer.Parse(data)
+	if err != nil {
+		t.Fatalf("parse %s: %v", format, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	recorder := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(recorder)
+	c.Request = httptest.NewRequest(http.MethodPost, "/tasks/import?format="+format+"&dryRun=true", nil)
+
+	importRows(c, models.User{}, rows)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("%s: status %d: %s", format, recorder.Code, recorder.Body.String())
+	}
+
+	var response struct {
+		Valid  int           `json:"valid"`
+		Errors []importError `json:"errors"`
+	}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &response); err != nil {
+		t.Fatal(err)
+	}
+	return response.Valid, response.Errors
+}
+
+func readFixture(t *testing.T, file string) []byte {
+	t.Helper()
+
+	data, err := os.ReadFile("../importers/testdata/" + file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+func TestImportRowsAllowEmptyDescriptions(t *testing.T) {
+	tests := []struct {
+		format string
+		data   []byte
+		valid  int
+		failed []int //rows of the file reported as errors
+	}{
+		//only the row with an unknown priority fails
+		{"todoist", readFixture(t, "todoist.csv"), 4, []int{10}},
+		//"Pay rent" has no annotations, deleted, recurring and unparsable due rows fail
+		{"taskwarrior", readFixture(t, "taskwarrior.json"), 2, []int{3, 4, 5}},
+		{"md", []byte("# Tasks\n\n## Home\n\n- [ ] Pay rent\n- [x] Inbox chore\n  with a note\n"), 2, []int{}},
+	}
+
+	for _, test := range tests {
+		valid, failed := dryRunImport(t, test.format, test.data)
+		for _, row := range failed {
+			if row.Error == "Description must be at most 870 characters!" {
+				t.Errorf("%s: row %d rejected for its description", test.format, row.Row)
+			}
+		}
+		if valid != test.valid {
+			t.Errorf("%s: %d valid rows, want %d (errors %+v)", test.format, valid, test.valid, failed)
+		}
+		rows := make([]int, 0, len(failed))
+		for _, row := range failed {
+			rows = append(rows, row.Row)
+		}
+		if !slices.Equal(rows, test.failed) {
+			t.Errorf("%s: failed rows %v, want %v", test.format, rows, test.failed)
+		}
+	}
+}
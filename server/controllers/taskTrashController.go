@@ -0,0 +1,155 @@
+package controllers
+
+import (
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"net/http"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"server/utils"
+	"strconv"
+	"time"
+)
+
+// trashed task with the moment it gets purged
+type trashedTask struct {
+	models.TasksModel
+	PurgeAt time.Time `json:"purgeAt"`
+}
+
+// bring trashed tasks back to the end of their project, tasks of deleted projects go to the inbox
+func restoreTasks(tx *gorm.DB, userID uint, tasks []models.TasksModel) error {
+	for i := range tasks {
+		task := &tasks[i]
+		if task.ProjectID != nil && !projectExists(userID, *task.ProjectID) {
+			task.ProjectID = nil
+		}
+		task.Order = nextOrder(tx, userID, task.ProjectID)
+		task.DeletedAt = gorm.DeletedAt{}
+
+		if err := tx.Unscoped().Model(task).Updates(map[string]interface{}{
+			"deleted_at": nil,
+			"project_id": task.ProjectID,
+			"order":      task.Order,
+			"version":    utils.BumpTaskVersion(),
+		}).Error; err != nil {
+			return err
+		}
+		task.Version++
+
+		if err := utils.RecordTaskChange(tx, *task, utils.TaskChangeUpsert); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func GetTrash(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var tasks []models.TasksModel
+	if err := initializers.DB.Unscoped().
+		Where("user_id = ? AND deleted_at IS NOT NULL", currentUser.ID).
+		Order("deleted_at desc").
+		Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch trash!"})
+		return
+	}
+
+	retention := utils.TrashRetentionDays()
+	trash := make([]trashedTask, 0, len(tasks))
+	for _, task := range tasks {
+		trash = append(trash, trashedTask{TasksModel: task, PurgeAt: task.DeletedAt.Time.AddDate(0, 0, retention)})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": trash, "retentionDays": retention})
+}
+
+func RestoreTask(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	localTaskID, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong task id!"})
+		return
+	}
+
+	var task models.TasksModel
+	if err := initializers.DB.Unscoped().
+		Where("local_id = ? AND user_id = ? AND deleted_at IS NOT NULL", localTaskID, currentUser.ID).
+		First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a task in trash!"})
+		return
+	}
+
+	tasks := []models.TasksModel{task}
+	if err := initializers.DB.Transaction(func(tx *gorm.DB) error { return restoreTasks(tx, currentUser.ID, tasks) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant restore task!"})
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": tasks[0]})
+}
+
+// restore the given local ids, or the whole trash with all set
+func RestoreTasks(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var input struct {
+		LocalIDs []uint `json:"localIds"`
+		All      bool   `json:"all"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !input.All && len(input.LocalIDs) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Nothing to restore!"})
+		return
+	}
+
+	query := initializers.DB.Unscoped().Where("user_id = ? AND deleted_at IS NOT NULL", currentUser.ID)
+	if !input.All {
+		query = query.Where("local_id IN ?", input.LocalIDs)
+	}
+
+	//oldest first, so restored tasks keep their relative order
+	var tasks []models.TasksModel
+	if err := query.Order("`order` asc").Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch trash!"})
+		return
+	}
+
+	if err := initializers.DB.Transaction(func(tx *gorm.DB) error { return restoreTasks(tx, currentUser.ID, tasks) }); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant restore tasks!"})
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"data": tasks, "count": len(tasks)})
+}
+
+// permanently delete everything in the trash
+func EmptyTrash(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	purged, err := utils.PurgeTrashedTasks(initializers.DB, func(db *gorm.DB) *gorm.DB {
+		return db.Where("user_id = ?", currentUser.ID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant empty trash!"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Trash emptied!", "count": purged})
+}
@@ -2,9 +2,11 @@ package controllers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
 	"net/http"
+	"server/cache"
 	"server/initializers"
 	"server/models"
 	"server/utils"
@@ -12,39 +14,189 @@ import (
 	"time"
 )
 
-const (
-	TasksCachePrefix = "tasks:"
-	TasksCacheTTL    = 15 * time.Minute
-)
+// next free local id of the user (if no tasks, localid = 1), trashed tasks keep their ids
+func nextLocalID(db *gorm.DB, userID uint) uint {
+	var lastTask models.TasksModel
+	db.Unscoped().Where("user_id = ?", userID).Order("local_id desc").First(&lastTask)
+	return lastTask.LocalID + 1
+}
+
+// tasks of a project query value, a project id or none for the inbox
+func projectScope(value string) (func(*gorm.DB) *gorm.DB, bool) {
+	if value == "none" {
+		return func(db *gorm.DB) *gorm.DB { return db.Where("project_id IS NULL") }, true
+	}
+
+	projectID, err := strconv.Atoi(value)
+	if err != nil || projectID <= 0 {
+		return nil, false
+	}
+	return func(db *gorm.DB) *gorm.DB { return db.Where("project_id = ?", projectID) }, true
+}
+
+// tasks in the same project as projectID
+func sameProject(projectID *uint) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		if projectID == nil {
+			return db.Where("project_id IS NULL")
+		}
+		return db.Where("project_id = ?", *projectID)
+	}
+}
+
+// order slot after the last task of the user in a project
+func nextOrder(db *gorm.DB, userID uint, projectID *uint) int {
+	var maxOrder int
+	result := db.Model(&models.TasksModel{}).
+		Where("user_id = ?", userID).
+		Scopes(sameProject(projectID)).
+		Select("COALESCE(MAX(`order`), 0)").
+		Scan(&maxOrder)
+
+	if result.Error != nil {
+		maxOrder = 0
+	}
 
-// Generate cache key for task lists with filters
-func getTasksListCacheKey(userID uint, hideCompleted bool, showTodayOnly bool) string {
-	return fmt.Sprintf("%s%d:hideCompleted:%t:todayOnly:%t", TasksCachePrefix, userID, hideCompleted, showTodayOnly)
+	return maxOrder + 1
 }
 
-// cache tasks list by filters
-func cacheTaskList(userID uint, hideCompleted bool, showTodayOnly bool, tasks []models.TasksModel) error {
-	tasksJSON, err := json.Marshal(tasks)
+// create the next occurrence of a completed recurring task, the rule moves to the new task
+func spawnNextOccurrence(tx *gorm.DB, task *models.TasksModel, loc *time.Location) (*models.TasksModel, error) {
+	rule, err := utils.ParseRecurrence(task.Recurrence)
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	now := time.Now()
+	current := now
+	if task.DueAt != nil {
+		current = *task.DueAt
+	}
+
+	next := models.TasksModel{
+		UserID:      task.UserID,
+		Title:       task.Title,
+		Description: task.Description,
+		Recurrence:  task.Recurrence,
+		ProjectID:   task.ProjectID,
+		Priority:    task.Priority,
+	}
+
+	if err := tx.Model(task).Association("Tags").Find(&next.Tags); err != nil {
+		return nil, err
+	}
+
+	//checklist starts over unchecked
+	var items []models.TaskItem
+	if err := orderedItems(tx).Where("task_id = ?", task.ID).Find(&items).Error; err != nil {
+		return nil, err
+	}
+	for _, item := range items {
+		next.Items = append(next.Items, models.TaskItem{UserID: item.UserID, Title: item.Title, Order: item.Order})
+	}
+
+	if err := utils.UpdateTaskFields(tx, task, map[string]interface{}{"recurrence": ""}); err != nil {
+		return nil, err
+	}
+
+	dueAt, ok := rule.NextAfter(current, now, loc)
+	if !ok {
+		//series ended
+		return nil, nil
+	}
+
+	next.LocalID = nextLocalID(tx, task.UserID)
+	next.Order = nextOrder(tx, task.UserID, task.ProjectID)
+	utils.SetTaskDue(&next, &dueAt, task.ReminderOffset)
+
+	if err := tx.Create(&next).Error; err != nil {
+		return nil, err
+	}
+	if err := utils.RecordTaskChange(tx, next, utils.TaskChangeUpsert); err != nil {
+		return nil, err
+	}
+	return &next, nil
+}
+
+// due date columns of a task after utils.SetTaskDue
+func dueFields(task models.TasksModel) map[string]interface{} {
+	return map[string]interface{}{
+		"due_at":            task.DueAt,
+		"reminder_offset":   task.ReminderOffset,
+		"remind_at":         task.RemindAt,
+		"reminder_fired_at": task.ReminderFiredAt,
+	}
+}
+
+// current task version as the response ETag
+func setTaskETag(c *gin.Context, task models.TasksModel) {
+	c.Header("ETag", utils.TaskETag(task))
+}
+
+// responds 409 with the current server copy of the task
+func taskConflict(c *gin.Context, taskID uint) {
+	var current models.TasksModel
+	if err := initializers.DB.Preload("Tags").First(&current, taskID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a task!"})
+		return
+	}
+
+	setTaskETag(c, current)
+	c.JSON(http.StatusConflict, gin.H{"conflictError": "Task was changed by another client!", "data": current})
+}
+
+// false when the If-Match header names an older version of the task, responds with the conflict
+func checkTaskVersion(c *gin.Context, task models.TasksModel) bool {
+	if utils.TaskMatches(c.GetHeader("If-Match"), task) {
+		return true
 	}
 
-	key := getTasksListCacheKey(userID, hideCompleted, showTodayOnly)
-	return initializers.RedisClient.Set(initializers.Ctx, key, tasksJSON, TasksCacheTTL).Err()
+	taskConflict(c, task.ID)
+	return false
 }
 
-// invalidate all task caches for a user
-func invalidateUserTaskCaches(userID uint) {
-	// get all keys with the users prefix
-	pattern := fmt.Sprintf("%s%d:*", TasksCachePrefix, userID)
-	keys, err := initializers.RedisClient.Keys(initializers.Ctx, pattern).Result()
+// field level update of a task read by the handler, responds on a conflict or failure
+func saveTaskFields(c *gin.Context, task *models.TasksModel, fields map[string]interface{}, failMessage string) bool {
+	err := utils.UpdateTaskFields(initializers.DB, task, fields)
+	if errors.Is(err, utils.ErrTaskVersionConflict) {
+		taskConflict(c, task.ID)
+		return false
+	}
 	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": failMessage})
+		return false
+	}
+
+	return true
+}
+
+// single task with its ETag, 304 when the client copy is current
+func GetTask(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
 		return
 	}
 
-	if len(keys) > 0 {
-		initializers.RedisClient.Del(initializers.Ctx, keys...)
+	setTaskETag(c, task)
+	if match := c.GetHeader("If-None-Match"); match != "" && utils.TaskMatches(match, task) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if err := initializers.DB.Model(&task).Association("Tags").Find(&task.Tags); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch task!"})
+		return
 	}
+	if err := orderedItems(initializers.DB).Where("task_id = ?", task.ID).Find(&task.Items).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch task!"})
+		return
+	}
+	task.Progress = utils.TaskProgress(task.Items)
+
+	c.JSON(http.StatusOK, gin.H{"data": task})
 }
 
 func GetAllTasks(c *gin.Context) {
@@ -54,17 +206,66 @@ func GetAllTasks(c *gin.Context) {
 	//get filter params from req
 	hideCompleted := c.Query("hideCompleted") == "true"
 	showTodayOnly := c.Query("showTodayOnly") == "true"
+	due := c.Query("due")
+	project := c.Query("project")
+	tag := utils.NormalizeTag(c.Query("tag"))
+	sort := c.DefaultQuery("sort", utils.SortManual)
 
-	cacheKey := getTasksListCacheKey(currentUser.ID, hideCompleted, showTodayOnly)
-	cachedTasks, err := initializers.RedisClient.Get(initializers.Ctx, cacheKey).Result()
-	if err == nil {
-		// Found in cache
-		var tasks []models.TasksModel
-		if err := json.Unmarshal([]byte(cachedTasks), &tasks); err == nil {
-			c.JSON(http.StatusOK, gin.H{"data": tasks})
+	if !utils.IsValidTaskSort(sort) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Sort must be manual, priority, due, created or smart!"})
+		return
+	}
+
+	if due != "" && !utils.IsValidDueFilter(due) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Due filter must be overdue, today or week!"})
+		return
+	}
+
+	var inProject func(*gorm.DB) *gorm.DB
+	if project != "" {
+		var ok bool
+		if inProject, ok = projectScope(project); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Project must be a project id or none!"})
 			return
 		}
+	}
+
+	//today is the calendar day of the user timezone
+	now := time.Now()
+	loc := utils.UserLocation(currentUser)
+	startOfDay := utils.StartOfDay(now, loc)
+	today := ""
+	if showTodayOnly {
+		today = startOfDay.Format(time.DateOnly)
+	}
+
+	dueFrom, dueTo := utils.DueRange(due, now, loc)
+	dueKey := ""
+	if due != "" {
+		dueKey = due + ":" + dueFrom.Format(time.DateOnly)
+	}
+
+	//overdue changes every moment, so it is never cached
+	useCache := due != utils.DueOverdue
+	cacheKey := cache.GetTasksListCacheKey(currentUser.ID, cache.TaskListFilters{
+		HideCompleted: hideCompleted,
+		Today:         today,
+		Due:           dueKey,
+		Project:       project,
+		Tag:           tag,
+		Sort:          sort,
+	})
+	if useCache {
+		cachedTasks, err := initializers.RedisClient.Get(initializers.Ctx, cacheKey).Result()
+		if err == nil {
+			// Found in cache
+			var tasks []models.TasksModel
+			if err := json.Unmarshal([]byte(cachedTasks), &tasks); err == nil {
+				c.JSON(http.StatusOK, gin.H{"data": tasks})
+				return
+			}
 
+		}
 	}
 
 	query := initializers.DB.Where("user_id = ?", currentUser.ID)
@@ -74,81 +275,180 @@ func GetAllTasks(c *gin.Context) {
 	}
 
 	if showTodayOnly {
-		now := time.Now()
-		startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
-		endOfDay := startOfDay.Add(24 * time.Hour)
+		endOfDay := startOfDay.AddDate(0, 0, 1)
 		query = query.Where("created_at >= ? AND created_at < ?", startOfDay, endOfDay)
 	}
 
+	if inProject != nil {
+		query = query.Scopes(inProject)
+	}
+
+	if tag != "" {
+		query = query.Where("id IN (?)", taggedTasks(currentUser.ID, tag))
+	}
+
+	switch due {
+	case utils.DueOverdue:
+		query = query.Where("due_at < ? AND completed = ?", dueTo, false)
+	case utils.DueToday, utils.DueWeek:
+		query = query.Where("due_at >= ? AND due_at < ?", dueFrom, dueTo)
+	}
+
 	var tasks []models.TasksModel
-	if err := query.Order("\"order\" asc").Find(&tasks).Error; err != nil {
+	if err := query.Preload("Items", orderedItems).Preload("Tags").Order(utils.TaskSortOrder(sort)).Find(&tasks).Error; err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "No tasks found!"})
 		return
 	}
 
-	go cacheTaskList(currentUser.ID, hideCompleted, showTodayOnly, tasks)
+	if sort == utils.SortSmart {
+		utils.SmartSortTasks(tasks, now)
+	}
+
+	for i := range tasks {
+		tasks[i].Progress = utils.TaskProgress(tasks[i].Items)
+	}
+
+	if useCache {
+		go cache.CacheTaskList(cacheKey, tasks)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"data": tasks})
 }
 
-func CreateTask(c *gin.Context) {
-	//get authenticated user from ctx
+// open tasks whose reminder already fired
+func GetTaskReminders(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
-	var input struct {
-		Title       string `json:"title" binding:"required"`
-		Description string `json:"description"`
-	}
-
-	if err := c.ShouldBindJSON(&input); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	var tasks []models.TasksModel
+	if err := initializers.DB.
+		Where("user_id = ? AND reminder_fired_at IS NOT NULL AND completed = ?", currentUser.ID, false).
+		Order("remind_at asc").
+		Find(&tasks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant fetch reminders!"})
 		return
 	}
 
+	c.JSON(http.StatusOK, gin.H{"data": tasks})
+}
+
+// body of a new task
+type taskInput struct {
+	Title          string     `json:"title" binding:"required"`
+	Description    string     `json:"description"`
+	DueAt          *time.Time `json:"dueAt"`
+	ReminderOffset *int       `json:"reminderOffset"`
+	Recurrence     string     `json:"recurrence"`
+	ProjectID      *uint      `json:"projectId"`
+	Tags           []string   `json:"tags"`
+	Priority       string     `json:"priority"`
+}
+
+// validate a new task body, returns the task without local id and order plus its tag names,
+// or the error status and body
+func newTaskFromInput(currentUser models.User, input taskInput) (models.TasksModel, []string, int, gin.H) {
+	var task models.TasksModel
+
 	if !utils.IsValidTitle(input.Title) {
-		c.JSON(http.StatusBadRequest, gin.H{"createTitleError": "Title must be between 2 and 95 characters!"})
-		return
+		return task, nil, http.StatusBadRequest, gin.H{"createTitleError": "Title must be between 2 and 95 characters!"}
 	}
 
-	if !utils.IsValidDescription(input.Description) {
-		c.JSON(http.StatusBadRequest, gin.H{"createDescriptionError": "Description must be  between 2 and 870 characters!"})
-		return
+	if input.ProjectID != nil && !projectExists(currentUser.ID, *input.ProjectID) {
+		return task, nil, http.StatusNotFound, gin.H{"error": "Cant find a project!"}
 	}
 
-	//get current user last localID value(if no tasks, localid = 0)
-	var lastTask models.TasksModel
-	initializers.DB.Where("user_id = ?", currentUser.ID).Order("local_id desc").First(&lastTask)
-	newLocalID := lastTask.LocalID + 1
+	priority := 0
+	if input.Priority != "" {
+		var ok bool
+		if priority, ok = utils.ParsePriority(input.Priority); !ok {
+			return task, nil, http.StatusBadRequest, gin.H{"createPriorityError": "Priority must be none, low, medium, high or urgent!"}
+		}
+	}
 
-	//get the highest order value
-	var maxOrder int
-	result := initializers.DB.Model(&models.TasksModel{}).
-		Where("user_id = ?", currentUser.ID).
-		Select("COALESCE(MAX(`order`), 0)").
-		Scan(&maxOrder)
+	tagNames, ok := normalizeTags(input.Tags)
+	if !ok {
+		return task, nil, http.StatusBadRequest, gin.H{"createTagsError": "Task can have at most 10 tags of 1 to 30 characters!"}
+	}
 
-	if result.Error != nil {
-		maxOrder = 0
+	if !utils.IsValidDescription(input.Description) {
+		return task, nil, http.StatusBadRequest, gin.H{"createDescriptionError": "Description must be  between 2 and 870 characters!"}
 	}
 
-	newOrder := maxOrder + 1
+	if !utils.IsValidReminderOffset(input.ReminderOffset, input.DueAt) {
+		return task, nil, http.StatusBadRequest, gin.H{"createDueError": "Reminder needs a due date and must be at most 7 days before it!"}
+	}
+
+	recurrence := ""
+	if input.Recurrence != "" {
+		anchor := time.Now()
+		if input.DueAt != nil {
+			anchor = *input.DueAt
+		}
+
+		var err error
+		if recurrence, err = utils.NormalizeRecurrence(input.Recurrence, anchor.In(utils.UserLocation(currentUser))); err != nil {
+			return task, nil, http.StatusBadRequest, gin.H{"createRecurrenceError": err.Error()}
+		}
+	}
 
-	task := models.TasksModel{
+	task = models.TasksModel{
 		UserID:      currentUser.ID,
-		LocalID:     newLocalID,
 		Title:       input.Title,
 		Description: input.Description,
 		Completed:   false,
-		Order:       newOrder,
+		Recurrence:  recurrence,
+		ProjectID:   input.ProjectID,
+		Priority:    priority,
+	}
+	utils.SetTaskDue(&task, input.DueAt, input.ReminderOffset)
+
+	return task, tagNames, http.StatusOK, nil
+}
+
+// create a validated task with its tags inside tx
+func insertTask(tx *gorm.DB, task *models.TasksModel, tagNames []string) error {
+	task.LocalID = nextLocalID(tx, task.UserID)
+	task.Order = nextOrder(tx, task.UserID, task.ProjectID)
+
+	tags, err := resolveTags(tx, task.UserID, tagNames)
+	if err != nil {
+		return err
+	}
+	task.Tags = tags
+
+	if err := tx.Create(task).Error; err != nil {
+		return err
+	}
+	return utils.RecordTaskChange(tx, *task, utils.TaskChangeUpsert)
+}
+
+func CreateTask(c *gin.Context) {
+	//get authenticated user from ctx
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var input taskInput
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
 	}
 
-	if err := initializers.DB.Create(&task).Error; err != nil {
+	task, tagNames, status, errorBody := newTaskFromInput(currentUser, input)
+	if errorBody != nil {
+		c.JSON(status, errorBody)
+		return
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		return insertTask(tx, &task, tagNames)
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant create a task!"})
 		return
 	}
 
-	invalidateUserTaskCaches(currentUser.ID)
+	cache.InvalidateUserTaskCaches(currentUser.ID)
 
 	c.JSON(http.StatusOK, gin.H{"data": task})
 }
@@ -171,13 +471,12 @@ func UpdateTaskTitle(c *gin.Context) {
 		return
 	}
 
-	var input struct {
-		Title string `json:"title" binding:"required"`
+	if !checkTaskVersion(c, task) {
+		return
 	}
 
-	if !utils.IsValidTitle(input.Title) {
-		c.JSON(http.StatusBadRequest, gin.H{"updateTitleError": "Title must be between 2 and 95 characters!"})
-		return
+	var input struct {
+		Title string `json:"title" binding:"required"`
 	}
 
 	if err := c.ShouldBindJSON(&input); err != nil {
@@ -185,15 +484,18 @@ func UpdateTaskTitle(c *gin.Context) {
 		return
 	}
 
-	task.Title = input.Title
+	if !utils.IsValidTitle(input.Title) {
+		c.JSON(http.StatusBadRequest, gin.H{"updateTitleError": "Title must be between 2 and 95 characters!"})
+		return
+	}
 
-	if err := initializers.DB.Save(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant update task title!"})
+	if !saveTaskFields(c, &task, map[string]interface{}{"title": input.Title}, "Cant update task title!") {
 		return
 	}
 
-	invalidateUserTaskCaches(currentUser.ID)
+	cache.InvalidateUserTaskCaches(currentUser.ID)
 
+	setTaskETag(c, task)
 	c.JSON(http.StatusOK, gin.H{"data": task})
 }
 
@@ -216,6 +518,10 @@ func UpdateTaskDescription(c *gin.Context) {
 		return
 	}
 
+	if !checkTaskVersion(c, task) {
+		return
+	}
+
 	var input struct {
 		Description string `json:"description" binding:"required"`
 	}
@@ -230,17 +536,165 @@ func UpdateTaskDescription(c *gin.Context) {
 		return
 	}
 
-	task.Description = input.Description
+	if !saveTaskFields(c, &task, map[string]interface{}{"description": input.Description}, "Cant update task description!") {
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	setTaskETag(c, task)
+	c.JSON(http.StatusOK, gin.H{"data": task})
+
+}
+
+func UpdateTaskDue(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	localTaskIDStr := c.Param("id")
+	localTaskID, err := strconv.Atoi(localTaskIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong task id!"})
+		return
+	}
+
+	var task models.TasksModel
+
+	if err := initializers.DB.Where("local_id = ? AND user_id = ?", localTaskID, currentUser.ID).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a task!"})
+		return
+	}
+
+	if !checkTaskVersion(c, task) {
+		return
+	}
+
+	//null dueAt clears the due date and the reminder
+	var input struct {
+		DueAt          *time.Time `json:"dueAt"`
+		ReminderOffset *int       `json:"reminderOffset"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !utils.IsValidReminderOffset(input.ReminderOffset, input.DueAt) {
+		c.JSON(http.StatusBadRequest, gin.H{"updateDueError": "Reminder needs a due date and must be at most 7 days before it!"})
+		return
+	}
+
+	due := task
+	utils.SetTaskDue(&due, input.DueAt, input.ReminderOffset)
+
+	fields := dueFields(due)
+	//the series follows the new time of day
+	if task.Recurrence != "" && due.DueAt != nil {
+		fields["recurrence"] = utils.PinRecurrenceTime(task.Recurrence, due.DueAt.In(utils.UserLocation(currentUser)))
+	}
+
+	if !saveTaskFields(c, &task, fields, "Cant update task due date!") {
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	setTaskETag(c, task)
+	c.JSON(http.StatusOK, gin.H{"data": task})
+}
+
+func UpdateTaskPriority(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	task, ok := findTask(c, currentUser.ID)
+	if !ok {
+		return
+	}
+
+	if !checkTaskVersion(c, task) {
+		return
+	}
+
+	var input struct {
+		Priority string `json:"priority" binding:"required"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	priority, ok := utils.ParsePriority(input.Priority)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"updatePriorityError": "Priority must be none, low, medium, high or urgent!"})
+		return
+	}
 
-	if err := initializers.DB.Save(&task).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant update task description!"})
+	if !saveTaskFields(c, &task, map[string]interface{}{"priority": priority}, "Cant update task priority!") {
 		return
 	}
 
-	invalidateUserTaskCaches(currentUser.ID)
+	cache.InvalidateUserTaskCaches(currentUser.ID)
 
+	setTaskETag(c, task)
 	c.JSON(http.StatusOK, gin.H{"data": task})
+}
+
+func UpdateTaskRecurrence(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	localTaskIDStr := c.Param("id")
+	localTaskID, err := strconv.Atoi(localTaskIDStr)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Wrong task id!"})
+		return
+	}
+
+	var task models.TasksModel
+
+	if err := initializers.DB.Where("local_id = ? AND user_id = ?", localTaskID, currentUser.ID).First(&task).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Cant find a task!"})
+		return
+	}
+
+	if !checkTaskVersion(c, task) {
+		return
+	}
+
+	//empty recurrence stops the series
+	var input struct {
+		Recurrence string `json:"recurrence"`
+	}
+
+	if err := c.ShouldBindJSON(&input); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	recurrence := ""
+	if input.Recurrence != "" {
+		anchor := time.Now()
+		if task.DueAt != nil {
+			anchor = *task.DueAt
+		}
+
+		if recurrence, err = utils.NormalizeRecurrence(input.Recurrence, anchor.In(utils.UserLocation(currentUser))); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"updateRecurrenceError": err.Error()})
+			return
+		}
+	}
+
+	if !saveTaskFields(c, &task, map[string]interface{}{"recurrence": recurrence}, "Cant update task recurrence!") {
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(currentUser.ID)
 
+	setTaskETag(c, task)
+	c.JSON(http.StatusOK, gin.H{"data": task})
 }
 
 func CompleteTask(c *gin.Context) {
@@ -261,6 +715,10 @@ func CompleteTask(c *gin.Context) {
 		return
 	}
 
+	if !checkTaskVersion(c, task) {
+		return
+	}
+
 	var input struct {
 		Completed bool `json:"completed" `
 	}
@@ -270,16 +728,42 @@ func CompleteTask(c *gin.Context) {
 		return
 	}
 
-	task.Completed = input.Completed
+	justCompleted := input.Completed && !task.Completed
+
+	var next *models.TasksModel
+	err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+		if err := utils.UpdateTaskFields(tx, &task, map[string]interface{}{"completed": input.Completed}); err != nil {
+			return err
+		}
 
-	if err := initializers.DB.Save(&task).Error; err != nil {
+		if justCompleted && task.Recurrence != "" {
+			var err error
+			next, err = spawnNextOccurrence(tx, &task, utils.UserLocation(currentUser))
+			return err
+		}
+		return nil
+	})
+	if errors.Is(err, utils.ErrTaskVersionConflict) {
+		taskConflict(c, task.ID)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant complete task!"})
 		return
 	}
 
-	invalidateUserTaskCaches(currentUser.ID)
+	cache.InvalidateUserTaskCaches(currentUser.ID)
 
-	c.JSON(http.StatusOK, gin.H{"data": task})
+	setTaskETag(c, task)
+
+	if justCompleted {
+		go func() {
+			utils.RecordActivity(currentUser.ID, utils.ActivityTaskCompleted, time.Now())
+			utils.EmitAchievementEvent(currentUser.ID, utils.EventTaskCompleted)
+		}()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": task, "next": next})
 }
 
 func DeleteTask(c *gin.Context) {
@@ -300,50 +784,86 @@ func DeleteTask(c *gin.Context) {
 		return
 	}
 
-	//delete finded task
-	if err := initializers.DB.Unscoped().Delete(&task).Error; err != nil {
+	if !checkTaskVersion(c, task) {
+		return
+	}
+
+	//move finded task to the trash, checklist and tags stay for a restore
+	err = utils.DeleteTaskVersion(initializers.DB, &task)
+	if errors.Is(err, utils.ErrTaskVersionConflict) {
+		taskConflict(c, task.ID)
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant delete task!"})
 		return
 	}
 
-	invalidateUserTaskCaches(currentUser.ID)
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	if task.Completed {
+		go utils.RecordTasksCleared(currentUser.ID, 1)
+	}
 
-	c.JSON(http.StatusOK, gin.H{"message": "Task successfully deleted!"})
+	c.JSON(http.StatusOK, gin.H{"message": "Task moved to trash!"})
 }
 
 func DeleteAllTasks(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
-	if err := initializers.DB.Unscoped().Where("user_id = ?", currentUser.ID).Delete(&models.TasksModel{}).Error; err != nil {
+	var count int64
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		tasks := tx.Model(&models.TasksModel{}).Where("user_id = ?", currentUser.ID)
+		if err := utils.RecordTaskChanges(tx, utils.TaskChangeDelete, tasks); err != nil {
+			return err
+		}
+
+		result := tx.Where("user_id = ?", currentUser.ID).Delete(&models.TasksModel{})
+		count = result.RowsAffected
+		return result.Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant delete all tasks!"})
 		return
 	}
 
-	invalidateUserTaskCaches(currentUser.ID)
+	cache.InvalidateUserTaskCaches(currentUser.ID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "All tasks successfully deleted!"})
+	c.JSON(http.StatusOK, gin.H{"message": "All tasks moved to trash!", "count": count})
 }
 
 func DeleteAllCompletedTasks(c *gin.Context) {
 	user, _ := c.Get("user")
 	currentUser := user.(models.User)
 
-	result := initializers.DB.Unscoped().Where("user_id = ? AND completed = ?", currentUser.ID, true).Delete(&models.TasksModel{})
+	var count int64
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		tasks := tx.Model(&models.TasksModel{}).Where("user_id = ? AND completed = ?", currentUser.ID, true)
+		if err := utils.RecordTaskChanges(tx, utils.TaskChangeDelete, tasks); err != nil {
+			return err
+		}
 
-	if result.Error != nil {
+		result := tx.Where("user_id = ? AND completed = ?", currentUser.ID, true).Delete(&models.TasksModel{})
+		count = result.RowsAffected
+		return result.Error
+	})
+
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cant delete all completed tasks!"})
 		return
 	}
 
-	if result.RowsAffected == 0 {
+	if count == 0 {
 		c.JSON(http.StatusOK, gin.H{"message": "No completed tasks to delete"})
 		return
 	}
 
-	invalidateUserTaskCaches(currentUser.ID)
+	cache.InvalidateUserTaskCaches(currentUser.ID)
 
-	c.JSON(http.StatusOK, gin.H{"message": "All completed tasks successfully deleted!", "count": result.RowsAffected})
+	go utils.RecordTasksCleared(currentUser.ID, int(count))
+
+	c.JSON(http.StatusOK, gin.H{"message": "All completed tasks moved to trash!", "count": count})
 }
 
 func UpdateTasksOrder(c *gin.Context) {
@@ -360,21 +880,42 @@ func UpdateTasksOrder(c *gin.Context) {
 		return
 	}
 
+	//with a project only tasks of that project are reordered
+	scope := func(db *gorm.DB) *gorm.DB { return db }
+	if project := c.Query("project"); project != "" {
+		var ok bool
+		if scope, ok = projectScope(project); !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Project must be a project id or none!"})
+			return
+		}
+	}
+
 	tx := initializers.DB.Begin()
 
+	localIDs := make([]int, 0, len(input))
 	for _, item := range input {
+		localIDs = append(localIDs, item.LocalID)
+
 		if err := tx.Model(&models.TasksModel{}).
 			Where("local_id = ? AND user_id = ?", item.LocalID, currentUser.ID).
-			Update("order", item.Order).Error; err != nil {
+			Scopes(scope).
+			Updates(map[string]interface{}{"order": item.Order, "version": utils.BumpTaskVersion()}).Error; err != nil {
 			tx.Rollback()
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update tasks order!"})
 			return
 		}
 	}
 
+	reordered := tx.Model(&models.TasksModel{}).Where("local_id IN ? AND user_id = ?", localIDs, currentUser.ID).Scopes(scope)
+	if err := utils.RecordTaskChanges(tx, utils.TaskChangeUpsert, reordered); err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot update tasks order!"})
+		return
+	}
+
 	tx.Commit()
 
-	invalidateUserTaskCaches(currentUser.ID)
+	cache.InvalidateUserTaskCaches(currentUser.ID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Tasks order updated successfully!"})
 }
@@ -13,6 +13,7 @@ import (
 	"net/http"
 	"net/smtp"
 	"os"
+	"server/cache"
 	"server/initializers"
 	"server/models"
 	"server/utils"
@@ -568,6 +569,7 @@ func Validate(c *gin.Context) {
 		"email":    userModel.Email,
 		"username": userModel.Username,
 		"uniqueID": userModel.UniqueID,
+		"timezone": userModel.Timezone,
 	})
 }
 
@@ -610,6 +612,67 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	//pomodoro history delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.PomodoroSession{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's pomodoro history"})
+		return
+	}
+
+	//pomodoro profiles delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.PomodoroProfile{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's pomodoro profiles"})
+		return
+	}
+
+	//task change log delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.TaskChange{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's task changes"})
+		return
+	}
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.TaskChangeSequence{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's task changes"})
+		return
+	}
+
+	//calendar feed delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.CalendarFeed{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's calendar feed"})
+		return
+	}
+
+	//task tag links delete
+	if err := tx.Exec("DELETE FROM task_tags WHERE task_id IN (?)", tx.Unscoped().Model(&models.TasksModel{}).Select("id").Where("user_id = ?", userID)).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's task tags"})
+		return
+	}
+
+	//tags delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.Tag{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's tags"})
+		return
+	}
+
+	//projects delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.Project{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's projects"})
+		return
+	}
+
+	//checklist items delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.TaskItem{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's checklist items"})
+		return
+	}
+
 	//tasks delete
 	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.TasksModel{}).Error; err != nil {
 		tx.Rollback()
@@ -624,6 +687,20 @@ func DeleteUser(c *gin.Context) {
 		return
 	}
 
+	//activity log delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.ActivityLog{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's activity log"})
+		return
+	}
+
+	//achievements delete
+	if err := tx.Unscoped().Where("user_id = ?", userID).Delete(&models.UserAchievement{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete user's achievements"})
+		return
+	}
+
 	//user delete(not soft)
 	if err := tx.Unscoped().Delete(&currentUser).Error; err != nil {
 		tx.Rollback()
@@ -699,3 +776,37 @@ func ChangeUsername(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"success": "Username updated successfully!"})
 }
+
+func ChangeTimezone(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var body struct {
+		Timezone string `json:"timezone"`
+	}
+
+	if c.Bind(&body) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	if !utils.IsValidTimezone(body.Timezone) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown timezone, expected IANA name like Europe/Riga"})
+		return
+	}
+
+	invalidateUserCache(currentUser)
+
+	currentUser.Timezone = body.Timezone
+	if err := initializers.DB.Model(&currentUser).Update("timezone", body.Timezone).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update timezone"})
+		return
+	}
+
+	cacheUser(currentUser)
+
+	//daily task filters depend on the timezone
+	cache.InvalidateUserTaskCaches(currentUser.ID)
+
+	c.JSON(http.StatusOK, gin.H{"success": "Timezone updated successfully!", "timezone": currentUser.Timezone})
+}
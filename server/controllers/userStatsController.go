@@ -2,13 +2,29 @@ package controllers
 
 import (
 	"github.com/gin-gonic/gin"
-	"gorm.io/gorm"
 	"net/http"
 	"server/initializers"
 	"server/models"
+	"server/utils"
 	"time"
 )
 
+// stats payload shared by the streak endpoints
+func statsResponse(stats models.StatsModel) gin.H {
+	return gin.H{
+		"currentStreak": stats.CurrentStreak,
+		"highestStreak": stats.HighestStreak,
+		"totalVisits":   stats.TotalVisitDays,
+		"freezeTokens":  stats.FreezeTokens,
+		"frozenDays":    stats.FrozenDays,
+		"rules": gin.H{
+			"minTasksCompleted":     stats.MinTasksCompleted,
+			"minPomodorosCompleted": stats.MinPomodorosCompleted,
+			"minChatMessages":       stats.MinChatMessages,
+		},
+	}
+}
+
 func GetUserStats(c *gin.Context) {
 	user, exists := c.Get("user")
 	if !exists {
@@ -24,34 +40,14 @@ func GetUserStats(c *gin.Context) {
 		return
 	}
 
-	var stats models.StatsModel
-	result := initializers.DB.Where("user_id = ?", currentUser.ID).First(&stats)
-
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			stats = models.StatsModel{
-				UserID:         currentUser.ID,
-				CurrentStreak:  1,
-				HighestStreak:  1,
-				LastVisitDate:  time.Now(),
-				TotalVisitDays: 1,
-			}
-			if err := initializers.DB.Create(&stats).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stats"})
-				return
-			}
-		} else {
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
-			return
-		}
+	//streak is rebuilt in memory so missed days are reflected without new activity
+	stats, err := utils.StreakSnapshot(currentUser.ID, utils.UserLocation(currentUser))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{
-		"currentStreak": stats.CurrentStreak,
-		"highestStreak": stats.HighestStreak,
-		"totalVisits":   stats.TotalVisitDays,
-	})
-
+	c.JSON(http.StatusOK, statsResponse(stats))
 }
 
 func UpdateDailyStreak(c *gin.Context) {
@@ -72,86 +68,132 @@ func UpdateDailyStreak(c *gin.Context) {
 		return
 	}
 
-	//find user stats
-	var stats models.StatsModel
-	result := initializers.DB.Where("user_id = ?", currentUser.ID).First(&stats)
-
-	//if stats not found, create new
-	if result.Error != nil {
-		if result.Error == gorm.ErrRecordNotFound {
-			stats = models.StatsModel{
-				UserID:         currentUser.ID,
-				CurrentStreak:  1,
-				HighestStreak:  1,
-				LastVisitDate:  time.Now(),
-				TotalVisitDays: 1,
-			}
-			if err := initializers.DB.Create(&stats).Error; err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error": "Failed to create stats",
-				})
-				return
-			}
-			c.JSON(http.StatusOK, gin.H{
-				"currentStreak": stats.CurrentStreak,
-				"highestStreak": stats.HighestStreak,
-				"totalVisits":   stats.TotalVisitDays,
-				"lastVisitDate": stats.LastVisitDate,
-			})
-			return
-		}
+	//find or create user stats
+	stats, err := utils.LoadStats(currentUser.ID)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
 		return
 	}
 
-	//get today date and last visit date
-	today := time.Now().Truncate(24 * time.Hour)
-	lastVisit := stats.LastVisitDate.Truncate(24 * time.Hour)
+	now := time.Now()
+	loc := utils.UserLocation(currentUser)
 
-	//if last visit date is today date, nothing change
-	if today.Equal(lastVisit) {
-		c.JSON(http.StatusOK, gin.H{
-			"currentStreak": stats.CurrentStreak,
-			"highestStreak": stats.HighestStreak,
-			"totalVisits":   stats.TotalVisitDays,
-		})
+	//calculate day diff in calendar days of the user timezone
+	dayDiff := utils.DaysBetween(stats.LastVisitDate, now, loc)
+
+	//first visit of the day refreshes last visit date and total visit number
+	if dayDiff > 0 {
+		stats.LastVisitDate = now
+		stats.TotalVisitDays++
+
+		if err := initializers.DB.Model(&stats).Select("last_visit_date", "total_visit_days").Updates(&stats).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stats"})
+			return
+		}
+	}
+
+	if _, err := utils.LogActivity(currentUser.ID, utils.ActivityVisit, now, loc); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stats"})
 		return
 	}
 
-	//calculate day diff
-	dayDiff := int(today.Sub(lastVisit).Hours() / 24)
+	//streak itself comes from qualifying activity, not from visits,
+	//missed days only change it once per day
+	if dayDiff > 0 || !stats.ActivitySeeded {
+		stats, err = utils.RecomputeStreak(currentUser.ID, loc)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stats"})
+			return
+		}
+	}
 
-	//refresh streak value based on order of visit
-	if dayDiff == 1 {
-		//if visit is on day after, increment streak
-		stats.CurrentStreak++
+	response := statsResponse(stats)
+	response["dayDiff"] = dayDiff
+	c.JSON(http.StatusOK, response)
+}
+
+func UpdateStreakRules(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	var body struct {
+		MinTasksCompleted     int `json:"minTasksCompleted"`
+		MinPomodorosCompleted int `json:"minPomodorosCompleted"`
+		MinChatMessages       int `json:"minChatMessages"`
+	}
 
-		//Refresh Highest streak too if current streak is bigger
-		if stats.CurrentStreak > stats.HighestStreak {
-			stats.HighestStreak = stats.CurrentStreak
+	if c.Bind(&body) != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to read body"})
+		return
+	}
+
+	for _, value := range []int{body.MinTasksCompleted, body.MinPomodorosCompleted, body.MinChatMessages} {
+		if value < 0 || value > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Rule minimums must be between 0 and 100"})
+			return
 		}
-	} else {
-		//if order of visit is not consistent, reset current streak to 1
-		stats.CurrentStreak = 1
 	}
 
-	//refresh last visit date and total visit number
-	stats.LastVisitDate = time.Now()
-	stats.TotalVisitDays++
+	if body.MinTasksCompleted == 0 && body.MinPomodorosCompleted == 0 && body.MinChatMessages == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one rule must be enabled"})
+		return
+	}
 
-	//save changes to db
-	if err := initializers.DB.Save(&stats).Error; err != nil {
+	stats, err := utils.LoadStats(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "DB error"})
+		return
+	}
+
+	stats.MinTasksCompleted = body.MinTasksCompleted
+	stats.MinPomodorosCompleted = body.MinPomodorosCompleted
+	stats.MinChatMessages = body.MinChatMessages
+
+	if err := initializers.DB.Model(&stats).Select("min_tasks_completed", "min_pomodoros_completed", "min_chat_messages").Updates(&stats).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update streak rules"})
+		return
+	}
+
+	//history is replayed with the new rules
+	stats, err = utils.RecomputeStreak(currentUser.ID, utils.UserLocation(currentUser))
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stats"})
 		return
 	}
 
-	//return refreshed data
-	c.JSON(http.StatusOK, gin.H{
-		"currentStreak": stats.CurrentStreak,
-		"highestStreak": stats.HighestStreak,
-		"totalVisits":   stats.TotalVisitDays,
-		"dayDiff":       dayDiff,
-	})
+	c.JSON(http.StatusOK, statsResponse(stats))
+}
+
+func GetFocusStats(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	now := time.Now()
+	loc := utils.UserLocation(currentUser)
+
+	var sessions []models.PomodoroSession
+	err := initializers.DB.
+		Where("user_id = ? AND focus = ? AND completed = ? AND ended_at >= ?", currentUser.ID, true, true, utils.FocusStatsSince(now, loc)).
+		Find(&sessions).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch focus sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, utils.BuildFocusStats(sessions, now, loc))
+}
+
+func GetAchievements(c *gin.Context) {
+	user, _ := c.Get("user")
+	currentUser := user.(models.User)
+
+	achievements, err := utils.UserAchievements(currentUser.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch achievements"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"data": achievements})
 }
 
 //test update daily streak
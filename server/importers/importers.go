@@ -0,0 +1,58 @@
+package importers
+
+import (
+	"server/utils"
+	"sort"
+)
+
+// Importer turns a file exported by some tool into task rows
+type Importer interface {
+	//format name used in the import url
+	Name() string
+	//rows of the file, an error means the whole file is unreadable
+	Parse(data []byte) ([]utils.ImportRow, error)
+}
+
+var registry = make(map[string]Importer)
+
+// make an importer available under its name
+func Register(importer Importer) {
+	registry[importer.Name()] = importer
+}
+
+// importer for a format name
+func Get(name string) (Importer, bool) {
+	importer, ok := registry[name]
+	return importer, ok
+}
+
+// sorted names of all importers
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// own export formats
+type transferImporter struct {
+	format string
+}
+
+func (t transferImporter) Name() string {
+	return t.format
+}
+
+func (t transferImporter) Parse(data []byte) ([]utils.ImportRow, error) {
+	return utils.ParseTasks(t.format, data)
+}
+
+func init() {
+	Register(transferImporter{utils.TransferJSON})
+	Register(transferImporter{utils.TransferCSV})
+	Register(transferImporter{utils.TransferMarkdown})
+	Register(todoistImporter{})
+	Register(taskwarriorImporter{})
+}
@@ -0,0 +1,118 @@
+package importers
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"server/utils"
+	"strings"
+	"time"
+)
+
+// taskwarrior `task export` output, a json array or one json object per line in older versions
+type taskwarriorImporter struct{}
+
+// exported task, other attributes are ignored
+type taskwarriorTask struct {
+	Description string   `json:"description"`
+	Status      string   `json:"status"` //pending, waiting, completed, deleted or recurring
+	Project     string   `json:"project"`
+	Priority    string   `json:"priority"` //H, M or L
+	Due         string   `json:"due"`
+	Tags        []string `json:"tags"`
+	Annotations []struct {
+		Description string `json:"description"`
+	} `json:"annotations"`
+}
+
+var taskwarriorPriorities = map[string]string{"H": "high", "M": "medium", "L": "low"}
+
+func (taskwarriorImporter) Name() string {
+	return "taskwarrior"
+}
+
+func (taskwarriorImporter) Parse(data []byte) ([]utils.ImportRow, error) {
+	raw, err := splitTaskwarriorExport(data)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make([]utils.ImportRow, 0, len(raw))
+	for i, item := range raw {
+		row := utils.ImportRow{Row: i + 1}
+
+		var task taskwarriorTask
+		if err := json.Unmarshal(item, &task); err != nil {
+			row.Error = "invalid task: " + err.Error()
+			rows = append(rows, row)
+			continue
+		}
+
+		var notes []string
+		for _, annotation := range task.Annotations {
+			notes = append(notes, annotation.Description)
+		}
+
+		row.Task = utils.TransferTask{
+			Title:       task.Description,
+			Description: strings.Join(notes, "\n"),
+			Completed:   task.Status == "completed",
+			Order:       i + 1,
+			Priority:    taskwarriorPriorities[task.Priority],
+			Project:     task.Project,
+			Tags:        task.Tags,
+		}
+
+		switch task.Status {
+		case "deleted":
+			row.Error = "task is deleted in taskwarrior"
+		case "recurring":
+			//the template, its pending instances are exported as their own tasks
+			row.Error = "recurring templates are not imported"
+		}
+
+		if task.Due != "" && row.Error == "" {
+			due, err := time.Parse("20060102T150405Z", task.Due)
+			if err != nil {
+				row.Error = fmt.Sprintf("invalid due date %q", task.Due)
+			} else {
+				row.Task.DueAt = &due
+			}
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// json objects of the export in both the array and the line per task layout
+func splitTaskwarriorExport(data []byte) ([]json.RawMessage, error) {
+	trimmed := bytes.TrimSpace(data)
+	if bytes.HasPrefix(trimmed, []byte("[")) {
+		var raw []json.RawMessage
+		if err := json.Unmarshal(trimmed, &raw); err != nil {
+			return nil, errors.New("file is not a taskwarrior json export")
+		}
+		return raw, nil
+	}
+
+	var raw []json.RawMessage
+	scanner := bufio.NewScanner(bytes.NewReader(trimmed))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := bytes.TrimSuffix(bytes.TrimSpace(scanner.Bytes()), []byte(","))
+		if len(line) == 0 {
+			continue
+		}
+		raw = append(raw, json.RawMessage(bytes.Clone(line)))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(raw) == 0 {
+		return nil, errors.New("file is not a taskwarrior json export")
+	}
+	return raw, nil
+}
@@ -0,0 +1,75 @@
+package importers
+
+import (
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestTaskwarriorImport(t *testing.T) {
+	rows := parseFixture(t, "taskwarrior", "testdata/taskwarrior.json")
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want 5", len(rows))
+	}
+
+	rent := rows[0]
+	if rent.Error != "" || rent.Task.Title != "Pay rent" || rent.Task.Project != "home" || rent.Task.Priority != "high" {
+		t.Errorf("pending task: %+v", rent)
+	}
+	if due := time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC); rent.Task.DueAt == nil || !rent.Task.DueAt.Equal(due) {
+		t.Errorf("due %v, want %v", rent.Task.DueAt, due)
+	}
+	if !slices.Equal(rent.Task.Tags, []string{"bills"}) {
+		t.Errorf("tags %v, want [bills]", rent.Task.Tags)
+	}
+
+	release := rows[1]
+	if !release.Task.Completed || release.Task.Priority != "medium" {
+		t.Errorf("completed task: %+v", release.Task)
+	}
+	if release.Task.Description != "tag v2.0\nwrite notes" {
+		t.Errorf("annotations became %q", release.Task.Description)
+	}
+	if release.Task.DueAt != nil {
+		t.Errorf("task without due got %v", release.Task.DueAt)
+	}
+
+	for _, i := range []int{2, 3} {
+		if rows[i].Error == "" {
+			t.Errorf("%s status %q was not rejected", rows[i].Task.Title, []string{"", "", "deleted", "recurring"}[i])
+		}
+	}
+
+	if rows[4].Error == "" {
+		t.Error("unparsable due date was accepted")
+	}
+}
+
+func TestTaskwarriorImportLines(t *testing.T) {
+	rows := parseFixture(t, "taskwarrior", "testdata/taskwarrior-lines.json")
+	if len(rows) != 3 {
+		t.Fatalf("got %d rows, want 3", len(rows))
+	}
+
+	if rows[0].Task.Title != "Pay rent" || rows[0].Task.Priority != "high" || rows[0].Error != "" {
+		t.Errorf("first line: %+v", rows[0])
+	}
+	if !rows[1].Task.Completed || rows[1].Task.Description != "tag v2.0" {
+		t.Errorf("second line: %+v", rows[1])
+	}
+	if rows[2].Error == "" {
+		t.Error("deleted task was not rejected")
+	}
+	if rows[2].Row != 3 {
+		t.Errorf("row %d, want 3", rows[2].Row)
+	}
+}
+
+func TestTaskwarriorImportRejectsOtherFiles(t *testing.T) {
+	for _, data := range []string{"", "[not json", "title,description\n"} {
+		rows, err := (taskwarriorImporter{}).Parse([]byte(data))
+		if err == nil && (len(rows) == 0 || rows[0].Error == "") {
+			t.Errorf("%q parsed without errors", data)
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package importers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"server/utils"
+	"strconv"
+	"strings"
+)
+
+// todoist CSV backup, one file per project with TYPE,CONTENT,DESCRIPTION,PRIORITY,INDENT,...,DATE columns
+type todoistImporter struct{}
+
+// todoist priority 1 is the highest (red) and 4 the default
+var todoistPriorities = map[int]string{1: "urgent", 2: "high", 3: "medium", 4: "none"}
+
+func (todoistImporter) Name() string {
+	return "todoist"
+}
+
+func (todoistImporter) Parse(data []byte) ([]utils.ImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("todoist file has no header row")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToUpper(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["TYPE"]; !ok {
+		return nil, errors.New("todoist file has no TYPE column")
+	}
+	if _, ok := columns["CONTENT"]; !ok {
+		return nil, errors.New("todoist file has no CONTENT column")
+	}
+
+	var rows []utils.ImportRow
+	section := ""
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("todoist %v", err)
+		}
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		switch strings.ToLower(field("TYPE")) {
+		case "section":
+			//sections become projects, tasks before the first one stay in the inbox
+			section = field("CONTENT")
+			continue
+		case "task":
+		default:
+			//notes, metadata and blank separator rows
+			continue
+		}
+
+		title, tags := splitTodoistLabels(field("CONTENT"))
+		row := utils.ImportRow{Row: line, Task: utils.TransferTask{
+			Title:       title,
+			Description: field("DESCRIPTION"),
+			Order:       len(rows) + 1,
+			Project:     section,
+			Tags:        tags,
+		}}
+
+		if value := field("PRIORITY"); value != "" {
+			priority, err := strconv.Atoi(value)
+			if name, ok := todoistPriorities[priority]; err == nil && ok {
+				row.Task.Priority = name
+			} else {
+				row.Error = fmt.Sprintf("priority must be 1 to 4, got %q", value)
+			}
+		}
+
+		//natural language and recurring dates like "every monday" are left out, the task is still imported
+		if dueAt, err := utils.ParseTransferTime(field("DATE")); err == nil {
+			row.Task.DueAt = dueAt
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// content without its @labels, which are returned as tags
+func splitTodoistLabels(content string) (string, []string) {
+	var words, labels []string
+	for _, word := range strings.Fields(content) {
+		if strings.HasPrefix(word, "@") && len(word) > 1 {
+			labels = append(labels, word[1:])
+			continue
+		}
+		words = append(words, word)
+	}
+	return strings.Join(words, " "), labels
+}
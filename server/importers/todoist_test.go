@@ -0,0 +1,95 @@
+package importers
+
+import (
+	"os"
+	"server/utils"
+	"slices"
+	"testing"
+	"time"
+)
+
+func parseFixture(t *testing.T, format string, file string) []utils.ImportRow {
+	t.Helper()
+
+	data, err := os.ReadFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	importer, ok := Get(format)
+	if !ok {
+		t.Fatalf("importer %q is not registered", format)
+	}
+	rows, err := importer.Parse(data)
+	if err != nil {
+		t.Fatalf("parse %s: %v", file, err)
+	}
+	return rows
+}
+
+func TestTodoistImport(t *testing.T) {
+	rows := parseFixture(t, "todoist", "testdata/todoist.csv")
+	if len(rows) != 5 {
+		t.Fatalf("got %d rows, want 5 tasks without sections, notes and blank rows", len(rows))
+	}
+
+	tests := []struct {
+		title    string
+		project  string
+		priority string
+		tags     []string
+		due      string
+		err      bool
+	}{
+		{title: "Inbox chore", project: "", priority: "none", tags: []string{"home"}},
+		{title: "Write report", project: "Work", priority: "urgent", tags: []string{"work", "urgent"}, due: "2024-03-15T00:00:00Z"},
+		{title: "Review PRs", project: "Work", priority: "high", due: "2024-03-16T09:30:00Z"},
+		{title: "Water plants", project: "Home", priority: "medium"},
+		{title: "Broken priority", project: "Home", err: true},
+	}
+
+	for i, want := range tests {
+		row := rows[i]
+		if row.Task.Title != want.title {
+			t.Errorf("row %d: title %q, want %q", i, row.Task.Title, want.title)
+		}
+		if row.Task.Project != want.project {
+			t.Errorf("%s: project %q, want %q", want.title, row.Task.Project, want.project)
+		}
+		if (row.Error != "") != want.err {
+			t.Errorf("%s: error %q, want error %v", want.title, row.Error, want.err)
+		}
+		if want.err {
+			continue
+		}
+		if row.Task.Priority != want.priority {
+			t.Errorf("%s: priority %q, want %q", want.title, row.Task.Priority, want.priority)
+		}
+		if !slices.Equal(row.Task.Tags, want.tags) {
+			t.Errorf("%s: tags %v, want %v", want.title, row.Task.Tags, want.tags)
+		}
+
+		switch {
+		case want.due == "" && row.Task.DueAt != nil:
+			t.Errorf("%s: due %v, want none", want.title, row.Task.DueAt)
+		case want.due != "":
+			due, _ := time.Parse(time.RFC3339, want.due)
+			if row.Task.DueAt == nil || !row.Task.DueAt.Equal(due) {
+				t.Errorf("%s: due %v, want %v", want.title, row.Task.DueAt, due)
+			}
+		}
+	}
+
+	if rows[1].Task.Description != "Quarterly numbers" {
+		t.Errorf("description %q, want %q", rows[1].Task.Description, "Quarterly numbers")
+	}
+	//csv line numbers of the source file, not task indexes
+	if rows[0].Row != 2 || rows[1].Row != 5 {
+		t.Errorf("rows %d and %d, want lines 2 and 5", rows[0].Row, rows[1].Row)
+	}
+}
+
+func TestTodoistImportNeedsColumns(t *testing.T) {
+	if _, err := (todoistImporter{}).Parse([]byte("NAME,VALUE\nx,y\n")); err == nil {
+		t.Error("file without TYPE and CONTENT columns was accepted")
+	}
+}
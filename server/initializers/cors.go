@@ -0,0 +1,11 @@
+package initializers
+
+import "slices"
+
+// browser origins allowed to call the api and open websockets
+var AllowedOrigins = []string{"http://localhost:8000", "http://localhost:3000", "http://83.99.161.62:3000"}
+
+// clients without an Origin header are not browsers, so they cant be tricked into a cross site request
+func IsAllowedOrigin(origin string) bool {
+	return origin == "" || slices.Contains(AllowedOrigins, origin)
+}
@@ -6,7 +6,7 @@ import (
 )
 
 func SyncDatabase() {
-	err := DB.AutoMigrate(&models.User{}, &models.PomodoroModel{}, &models.TasksModel{}, &models.StatsModel{})
+	err := DB.AutoMigrate(&models.User{}, &models.PomodoroModel{}, &models.TasksModel{}, &models.StatsModel{}, &models.PomodoroSession{}, &models.PomodoroProfile{}, &models.ActivityLog{}, &models.UserAchievement{}, &models.TaskItem{}, &models.Project{}, &models.Tag{}, &models.CalendarFeed{}, &models.TaskChange{}, &models.TaskChangeSequence{})
 
 	if err != nil {
 		log.Fatalf("Could not migrate database: %v", err)
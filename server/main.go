@@ -6,6 +6,7 @@ import (
 	"log"
 	"server/initializers"
 	"server/routes"
+	"server/utils"
 	"time"
 )
 
@@ -15,6 +16,9 @@ func init() {
 	initializers.SyncDatabase()
 	initializers.ConnectToRedis()
 	initializers.InitOAuthConfigs()
+	utils.RecoverPomodoroTimers()
+	utils.StartReminderWorker()
+	utils.StartTrashPurgeWorker()
 }
 
 func main() {
@@ -28,10 +32,10 @@ func main() {
 	}
 
 	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"http://localhost:8000", "http://localhost:3000", "http://83.99.161.62:3000"},
+		AllowOrigins:     initializers.AllowedOrigins,
 		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowHeaders:     []string{"Origin", "Content-Length", "Content-Type", "Authorization", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           24 * time.Hour,
 	}))
@@ -40,6 +44,7 @@ func main() {
 	routes.UserRoutes(r)
 	routes.PomodoroRoutes(r)
 	routes.TasksRoutes(r)
+	routes.ProjectsRoutes(r)
 	routes.StatsRoutes(r)
 	routes.OAuthRoutes(r)
 	routes.ChatRoutes(r)
@@ -0,0 +1,14 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// achievement unlocked by a user, codes are declared in utils/achievements.go
+type UserAchievement struct {
+	gorm.Model
+	UserID     uint   `gorm:"uniqueIndex:idx_user_achievement"`
+	Code       string `gorm:"size:50;uniqueIndex:idx_user_achievement"`
+	UnlockedAt time.Time
+}
@@ -0,0 +1,14 @@
+package models
+
+import "gorm.io/gorm"
+
+// activity counters of one user on one calendar day of the user timezone
+type ActivityLog struct {
+	gorm.Model
+	UserID             uint   `gorm:"uniqueIndex:idx_activity_user_day"`
+	Day                string `gorm:"size:10;uniqueIndex:idx_activity_user_day"` //YYYY-MM-DD
+	Visits             int    `gorm:"default:0"`
+	TasksCompleted     int    `gorm:"default:0"`
+	PomodorosCompleted int    `gorm:"default:0"`
+	ChatMessages       int    `gorm:"default:0"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// secret url token of the task calendar feed of a user
+type CalendarFeed struct {
+	gorm.Model
+	UserID uint   `gorm:"uniqueIndex"`
+	Token  string `gorm:"size:64;uniqueIndex"`
+}
@@ -2,18 +2,44 @@ package models
 
 import (
 	"gorm.io/gorm"
+	"time"
 )
 
+// timer states, IsRunning mirrors TimerStateRunning
+const (
+	TimerStateIdle     = "idle"
+	TimerStateRunning  = "running"
+	TimerStatePaused   = "paused"
+	TimerStateFinished = "finished" //phase ended and the next one waits to be started
+)
+
+// user defined timer phase, duration in minutes
+type PhaseStep struct {
+	Name     string `json:"name"`
+	Duration int    `json:"duration"`
+	Focus    bool   `json:"focus"` //counts as a pomodoro when completed
+}
+
 type PomodoroModel struct {
 	gorm.Model
-	UserID                  uint   `gorm:"unique"`
-	PomodoroDuration        int    `gorm:"default:25"`
-	ShortBreakDuration      int    `gorm:"default:5"`
-	LongBreakDuration       int    `gorm:"default:15"`
-	IsRunning               bool   `gorm:"default:false"`
-	CurrentPhase            string `gorm:"default:'pomodoro'"`
-	RemainingTime           int    `gorm:"default:0"`
-	CompletedPomodoros      int    `gorm:"default:0"`
-	TotalCompletedPomodoros int    `gorm:"default:0"`
-	AutoTransition          bool   `gorm:"default:false"`
+	UserID                  uint        `gorm:"unique"`
+	PomodoroDuration        int         `gorm:"default:25"`
+	ShortBreakDuration      int         `gorm:"default:5"`
+	LongBreakDuration       int         `gorm:"default:15"`
+	LongBreakInterval       int         `gorm:"default:4"`                 //pomodoros before a long break
+	PhaseSequence           []PhaseStep `gorm:"serializer:json;type:text"` //replaces the classic cycle when not empty
+	SequenceIndex           int         `gorm:"default:0"`
+	IsRunning               bool        `gorm:"default:false"`
+	State                   string      `gorm:"default:'idle'"`
+	CurrentPhase            string      `gorm:"default:'pomodoro'"`
+	RemainingTime           int         `gorm:"default:0"` //seconds left while stopped, derived from PhaseEndsAt while running
+	PhaseStartedAt          *time.Time
+	PhaseEndsAt             *time.Time
+	PausedAt                *time.Time
+	PausedSeconds           int   `gorm:"default:0"` //paused time of the current phase
+	CompletedPomodoros      int   `gorm:"default:0"`
+	TotalCompletedPomodoros int   `gorm:"default:0"`
+	AutoTransition          bool  `gorm:"default:false"`
+	TaskLocalID             *uint //task the focused time is tracked against
+	ActiveProfileID         *uint
 }
@@ -0,0 +1,16 @@
+package models
+
+import "gorm.io/gorm"
+
+// named timer configuration, the running state stays on PomodoroModel
+type PomodoroProfile struct {
+	gorm.Model
+	UserID             uint `gorm:"index"`
+	Name               string
+	PomodoroDuration   int
+	ShortBreakDuration int
+	LongBreakDuration  int
+	LongBreakInterval  int         `gorm:"default:4"`
+	PhaseSequence      []PhaseStep `gorm:"serializer:json;type:text"`
+	AutoTransition     bool
+}
@@ -0,0 +1,21 @@
+package models
+
+import (
+	"gorm.io/gorm"
+	"time"
+)
+
+// one finished or aborted run of a timer phase
+type PomodoroSession struct {
+	gorm.Model
+	UserID          uint `gorm:"index"`
+	Phase           string
+	Focus           bool
+	PlannedDuration int //seconds
+	ActualDuration  int //seconds, without pauses
+	PausedDuration  int //seconds
+	StartedAt       time.Time
+	EndedAt         time.Time `gorm:"index"`
+	Completed       bool
+	TaskLocalID     *uint
+}
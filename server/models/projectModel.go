@@ -0,0 +1,11 @@
+package models
+
+import "gorm.io/gorm"
+
+// named task list, tasks without a project are in the inbox
+type Project struct {
+	gorm.Model
+	UserID uint   `gorm:"uniqueIndex:idx_user_project"`
+	Name   string `gorm:"size:50;uniqueIndex:idx_user_project"`
+	Order  int    `gorm:"default:0"`
+}
@@ -0,0 +1,10 @@
+package models
+
+import "gorm.io/gorm"
+
+// free-form task label, names are stored normalized
+type Tag struct {
+	gorm.Model
+	UserID uint   `gorm:"uniqueIndex:idx_user_tag"`
+	Name   string `gorm:"size:30;uniqueIndex:idx_user_tag"`
+}
@@ -0,0 +1,21 @@
+package models
+
+import "gorm.io/gorm"
+
+// one mutation of a task, the sequence number is the cursor of the sync protocol
+type TaskChange struct {
+	gorm.Model
+	UserID  uint   `gorm:"index;index:idx_task_change_seq,priority:1"`
+	TaskID  uint   `gorm:"index"`
+	LocalID uint   //kept so a purged task still has a tombstone
+	Op      string `gorm:"size:10"`                                        //upsert or delete
+	OpID    string `gorm:"size:64;index"`                                  //client operation of a sync that caused the change
+	Seq     uint   `gorm:"default:0;index:idx_task_change_seq,priority:2"` //0 until the change is committed and numbered
+}
+
+// last sequence number given to a change of the user, locked while numbering
+type TaskChangeSequence struct {
+	gorm.Model
+	UserID uint `gorm:"uniqueIndex"`
+	Seq    uint `gorm:"default:0"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "gorm.io/gorm"
+
+// checklist entry of a task
+type TaskItem struct {
+	gorm.Model
+	TaskID    uint `gorm:"index"`
+	UserID    uint `gorm:"index"`
+	Title     string
+	Completed bool `gorm:"default:false"`
+	Order     int  `gorm:"default:0"`
+}
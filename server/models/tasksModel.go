@@ -1,13 +1,29 @@
 package models
 
-import "gorm.io/gorm"
+import (
+	"gorm.io/gorm"
+	"time"
+)
 
 type TasksModel struct {
 	gorm.Model
-	UserID      uint
-	LocalID     uint
-	Title       string
-	Description string
-	Completed   bool `gorm:"default:false"`
-	Order       int  `gorm:"default:0"`
+	UserID          uint
+	LocalID         uint
+	Version         uint `gorm:"default:1;not null"` //bumped on every client change, sent as the task ETag
+	Title           string
+	Description     string
+	Completed       bool       `gorm:"default:false"`
+	Order           int        `gorm:"default:0"`
+	FocusSeconds    int        `gorm:"default:0"` //time tracked by pomodoros linked to the task
+	PomodoroCount   int        `gorm:"default:0"`
+	Priority        int        `gorm:"default:0;index"` //index in utils.TaskPriorities
+	DueAt           *time.Time `gorm:"index"`
+	ReminderOffset  *int       //minutes before DueAt, nil means no reminder
+	RemindAt        *time.Time `gorm:"index"` //DueAt minus the offset
+	ReminderFiredAt *time.Time //set by the reminder worker once RemindAt passed
+	Recurrence      string     `gorm:"size:255"` //canonical RRULE, moves to the next occurrence on completion
+	ProjectID       *uint      `gorm:"index"`    //nil for the inbox
+	Tags            []Tag      `gorm:"many2many:task_tags;joinForeignKey:TaskID;joinReferences:TagID"`
+	Items           []TaskItem `gorm:"foreignKey:TaskID"`
+	Progress        int        `gorm:"-"` //percent of completed items, filled for task lists
 }
@@ -28,10 +28,12 @@ type User struct {
 	Avatar                string
 	IsEmailConfirmed      bool
 	EmailConfirmationCode string
-	OAuthProvider         AuthProvider  `gorm:"default:'local'"`
-	OAuthProviderID       string        `gorm:"index"`
-	Tasks                 []TasksModel  //one-to-many
-	Pomodoro              PomodoroModel //one-to-one #mb need to rework to one-to-many
+	OAuthProvider         AuthProvider      `gorm:"default:'local'"`
+	OAuthProviderID       string            `gorm:"index"`
+	Timezone              string            `gorm:"size:64;default:'UTC'"` //IANA name, used for streaks and daily filters
+	Tasks                 []TasksModel      //one-to-many
+	Pomodoro              PomodoroModel     //one-to-one, running timer state
+	PomodoroProfiles      []PomodoroProfile //one-to-many, saved timer configs
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) (err error) {
@@ -12,4 +12,17 @@ type StatsModel struct {
 	HighestStreak  int
 	LastVisitDate  time.Time
 	TotalVisitDays int
+	FreezeTokens   int //cover a missed day automatically
+	FrozenDays     int //missed days covered in the current streak
+	TasksCleared   int //completed tasks deleted by the user
+
+	//activity log filled from older data, the streak counted before it is kept as qualifying days
+	ActivitySeeded  bool   `gorm:"default:false"`
+	LegacyStreak    int    //days of the streak before the activity log
+	LegacyStreakEnd string `gorm:"size:10"` //YYYY-MM-DD, last day of the legacy streak
+
+	//streak rules, a day counts when any enabled minimum is reached, 0 disables a rule
+	MinTasksCompleted     int `gorm:"default:1"`
+	MinPomodorosCompleted int `gorm:"default:1"`
+	MinChatMessages       int `gorm:"default:1"`
 }
@@ -15,4 +15,16 @@ func PomodoroRoutes(router *gin.Engine) {
 	router.POST("/pomodoro-phase", middleware.RequireAuth, controllers.ChangePhase)
 	router.POST("/pomodoro-auto-mode", middleware.RequireAuth, controllers.UpdateAutoTransition)
 	router.POST("/pomodoro-reset", middleware.RequireAuth, controllers.ResetCompletedPomodoros)
+
+	router.POST("/pomodoro/pause", middleware.RequireAuth, controllers.PausePomodoro)
+	router.POST("/pomodoro/resume", middleware.RequireAuth, controllers.ResumePomodoro)
+	router.POST("/pomodoro/skip", middleware.RequireAuth, controllers.SkipPomodoroPhase)
+	router.POST("/pomodoro/abandon", middleware.RequireAuth, controllers.AbandonPomodoro)
+	router.GET("/pomodoro/profiles", middleware.RequireAuth, controllers.GetPomodoroProfiles)
+	router.POST("/pomodoro/profiles", middleware.RequireAuth, controllers.CreatePomodoroProfile)
+	router.PUT("/pomodoro/profiles/:id", middleware.RequireAuth, controllers.UpdatePomodoroProfile)
+	router.DELETE("/pomodoro/profiles/:id", middleware.RequireAuth, controllers.DeletePomodoroProfile)
+	router.POST("/pomodoro/profiles/:id/activate", middleware.RequireAuth, controllers.ActivatePomodoroProfile)
+	router.GET("/pomodoro/sessions", middleware.RequireAuth, controllers.GetPomodoroSessions)
+	router.GET("/pomodoro/ws", middleware.RequireAuth, controllers.PomodoroSocket)
 }
@@ -0,0 +1,19 @@
+package routes
+
+import (
+	"github.com/gin-gonic/gin"
+	"server/controllers"
+	"server/middleware"
+)
+
+func ProjectsRoutes(router *gin.Engine) {
+	router.GET("/projects", middleware.RequireAuth, controllers.GetProjects)
+	router.POST("/projects", middleware.RequireAuth, controllers.CreateProject)
+	router.PUT("/projects/:id", middleware.RequireAuth, controllers.RenameProject)
+	router.DELETE("/projects/:id", middleware.RequireAuth, controllers.DeleteProject)
+
+	router.GET("/tags", middleware.RequireAuth, controllers.GetTags)
+	router.PUT("/tags/:id", middleware.RequireAuth, controllers.RenameTag)
+	router.POST("/tags/:id/merge", middleware.RequireAuth, controllers.MergeTag)
+	router.DELETE("/tags/:id", middleware.RequireAuth, controllers.DeleteTag)
+}
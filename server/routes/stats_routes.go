@@ -9,6 +9,9 @@ import (
 func StatsRoutes(router *gin.Engine) {
 	router.GET("/stats", middleware.RequireAuth, controllers.GetUserStats)
 	router.POST("/stats/update-streak", middleware.RequireAuth, controllers.UpdateDailyStreak)
+	router.PUT("/stats/streak-rules", middleware.RequireAuth, controllers.UpdateStreakRules)
+	router.GET("/stats/focus", middleware.RequireAuth, controllers.GetFocusStats)
+	router.GET("/stats/achievements", middleware.RequireAuth, controllers.GetAchievements)
 
 	//router.POST("/test/set-last-visit", middleware.RequireAuth, controllers.TestLastVisitDate)
 }
@@ -8,12 +8,39 @@ import (
 
 func TasksRoutes(router *gin.Engine) {
 	router.GET("/tasks", middleware.RequireAuth, controllers.GetAllTasks)
+	router.GET("/tasks/reminders", middleware.RequireAuth, controllers.GetTaskReminders)
+	router.GET("/tasks/search", middleware.RequireAuth, controllers.SearchTasks)
+	router.GET("/tasks/trash", middleware.RequireAuth, controllers.GetTrash)
+	router.DELETE("/tasks/trash", middleware.RequireAuth, controllers.EmptyTrash)
+	router.POST("/tasks/restore", middleware.RequireAuth, controllers.RestoreTasks)
+	router.POST("/task/restore/:id", middleware.RequireAuth, controllers.RestoreTask)
 	router.POST("/tasks-create", middleware.RequireAuth, controllers.CreateTask)
+	router.POST("/tasks/batch", middleware.RequireAuth, controllers.BatchTasks)
+	router.POST("/tasks/sync", middleware.RequireAuth, controllers.SyncTasks)
+	router.GET("/tasks/export", middleware.RequireAuth, controllers.ExportTasks)
+	router.POST("/tasks/import", middleware.RequireAuth, controllers.ImportTasks)
+	router.GET("/tasks/ical-feed", middleware.RequireAuth, controllers.GetCalendarFeed)
+	router.POST("/tasks/ical-feed/rotate", middleware.RequireAuth, controllers.RotateCalendarToken)
+	router.DELETE("/tasks/ical-feed", middleware.RequireAuth, controllers.DisableCalendarFeed)
+	//calendar apps cant send the auth cookie, the token authorizes the feed
+	router.GET("/tasks/ical/:token", controllers.TaskCalendar)
 	router.PUT("/task/update-description/:id", middleware.RequireAuth, controllers.UpdateTaskDescription)
 	router.PUT("/task/update-title/:id", middleware.RequireAuth, controllers.UpdateTaskTitle)
+	router.PUT("/task/update-due/:id", middleware.RequireAuth, controllers.UpdateTaskDue)
+	router.PUT("/task/update-recurrence/:id", middleware.RequireAuth, controllers.UpdateTaskRecurrence)
+	router.PUT("/task/update-priority/:id", middleware.RequireAuth, controllers.UpdateTaskPriority)
+	router.PUT("/task/update-project/:id", middleware.RequireAuth, controllers.UpdateTaskProject)
+	router.PUT("/task/update-tags/:id", middleware.RequireAuth, controllers.UpdateTaskTags)
 	router.PUT("/task/complete/:id", middleware.RequireAuth, controllers.CompleteTask)
 	router.PUT("/tasks/order", middleware.RequireAuth, controllers.UpdateTasksOrder)
 	router.DELETE("/task/delete/:id", middleware.RequireAuth, controllers.DeleteTask)
 	router.DELETE("/task/delete-all", middleware.RequireAuth, controllers.DeleteAllTasks)
 	router.DELETE("/task/delete-completed", middleware.RequireAuth, controllers.DeleteAllCompletedTasks)
+
+	router.GET("/task/:id", middleware.RequireAuth, controllers.GetTask)
+	router.GET("/task/:id/items", middleware.RequireAuth, controllers.GetTaskItems)
+	router.POST("/task/:id/items", middleware.RequireAuth, controllers.CreateTaskItem)
+	router.PUT("/task/:id/items/order", middleware.RequireAuth, controllers.UpdateTaskItemsOrder)
+	router.PUT("/task/:id/items/:itemId", middleware.RequireAuth, controllers.UpdateTaskItem)
+	router.DELETE("/task/:id/items/:itemId", middleware.RequireAuth, controllers.DeleteTaskItem)
 }
@@ -19,6 +19,7 @@ func UserRoutes(router *gin.Engine) {
 		userGroup.POST("refresh-token", controllers.RefreshToken)
 
 		userGroup.PUT("update-username", middleware.RequireAuth, controllers.ChangeUsername)
+		userGroup.PUT("update-timezone", middleware.RequireAuth, controllers.ChangeTimezone)
 
 		userGroup.DELETE("delete-user", middleware.RequireAuth, controllers.DeleteUser)
 	}
@@ -0,0 +1,190 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"server/initializers"
+	"server/models"
+	"slices"
+	"time"
+)
+
+// events which trigger achievement evaluation
+const (
+	EventPomodoroCompleted = "pomodoroCompleted"
+	EventTaskCompleted     = "taskCompleted"
+	EventTasksCleared      = "tasksCleared"
+	EventStreakUpdated     = "streakUpdated"
+)
+
+// achievement rule, unlocked once its metric reaches the threshold
+type Achievement struct {
+	Code        string   `json:"code"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Metric      string   `json:"metric"`
+	Threshold   int      `json:"threshold"`
+	Events      []string `json:"-"`
+}
+
+// all achievements, new ones only need an entry here
+var Achievements = []Achievement{
+	{Code: "first_pomodoro", Title: "First focus", Description: "Complete your first pomodoro", Metric: "pomodoros", Threshold: 1, Events: []string{EventPomodoroCompleted}},
+	{Code: "pomodoros_100", Title: "Centurion", Description: "Complete 100 pomodoros", Metric: "pomodoros", Threshold: 100, Events: []string{EventPomodoroCompleted}},
+	{Code: "pomodoros_500", Title: "Deep worker", Description: "Complete 500 pomodoros", Metric: "pomodoros", Threshold: 500, Events: []string{EventPomodoroCompleted}},
+	{Code: "streak_7", Title: "On a roll", Description: "Reach a 7-day streak", Metric: "streak", Threshold: 7, Events: []string{EventStreakUpdated}},
+	{Code: "streak_30", Title: "Habit formed", Description: "Reach a 30-day streak", Metric: "streak", Threshold: 30, Events: []string{EventStreakUpdated}},
+	{Code: "streak_100", Title: "Unstoppable", Description: "Reach a 100-day streak", Metric: "streak", Threshold: 100, Events: []string{EventStreakUpdated}},
+	{Code: "tasks_completed_10", Title: "Getting things done", Description: "Complete 10 tasks", Metric: "tasksCompleted", Threshold: 10, Events: []string{EventTaskCompleted}},
+	{Code: "tasks_completed_100", Title: "Task master", Description: "Complete 100 tasks", Metric: "tasksCompleted", Threshold: 100, Events: []string{EventTaskCompleted}},
+	{Code: "tasks_cleared_50", Title: "Clean slate", Description: "Clear 50 completed tasks", Metric: "tasksCleared", Threshold: 50, Events: []string{EventTasksCleared}},
+}
+
+// current value of every metric an achievement can use
+var achievementMetrics = map[string]func(userID uint) (int, error){
+	"pomodoros": func(userID uint) (int, error) {
+		var total int
+		err := initializers.DB.Model(&models.PomodoroModel{}).Where("user_id = ?", userID).
+			Select("COALESCE(MAX(total_completed_pomodoros), 0)").Scan(&total).Error
+		return total, err
+	},
+	"streak": func(userID uint) (int, error) {
+		var highest int
+		err := initializers.DB.Model(&models.StatsModel{}).Where("user_id = ?", userID).
+			Select("COALESCE(MAX(highest_streak), 0)").Scan(&highest).Error
+		return highest, err
+	},
+	"tasksCompleted": func(userID uint) (int, error) {
+		var total int
+		err := initializers.DB.Model(&models.ActivityLog{}).Where("user_id = ?", userID).
+			Select("COALESCE(SUM(tasks_completed), 0)").Scan(&total).Error
+		return total, err
+	},
+	"tasksCleared": func(userID uint) (int, error) {
+		var total int
+		err := initializers.DB.Model(&models.StatsModel{}).Where("user_id = ?", userID).
+			Select("COALESCE(MAX(tasks_cleared), 0)").Scan(&total).Error
+		return total, err
+	},
+}
+
+// codes of the achievements a user already unlocked
+func unlockedAchievements(userID uint) (map[string]models.UserAchievement, error) {
+	var unlocked []models.UserAchievement
+	if err := initializers.DB.Where("user_id = ?", userID).Find(&unlocked).Error; err != nil {
+		return nil, err
+	}
+
+	byCode := make(map[string]models.UserAchievement, len(unlocked))
+	for _, achievement := range unlocked {
+		byCode[achievement.Code] = achievement
+	}
+	return byCode, nil
+}
+
+// evaluate the rules listening to an event and unlock the reached ones
+func EmitAchievementEvent(userID uint, event string) {
+	unlocked, err := unlockedAchievements(userID)
+	if err != nil {
+		log.Printf("Failed to load achievements of user %d: %v", userID, err)
+		return
+	}
+
+	values := make(map[string]int)
+	for _, rule := range Achievements {
+		if _, ok := unlocked[rule.Code]; ok || !slices.Contains(rule.Events, event) {
+			continue
+		}
+
+		value, ok := values[rule.Metric]
+		if !ok {
+			value, err = achievementMetrics[rule.Metric](userID)
+			if err != nil {
+				log.Printf("Failed to compute %s metric of user %d: %v", rule.Metric, userID, err)
+				return
+			}
+			values[rule.Metric] = value
+		}
+
+		if value < rule.Threshold {
+			continue
+		}
+
+		unlockAchievement(userID, rule.Code)
+	}
+}
+
+// unlock an achievement once, later unlocks keep the first time
+func unlockAchievement(userID uint, code string) (models.UserAchievement, bool) {
+	achievement := models.UserAchievement{UserID: userID, Code: code, UnlockedAt: time.Now()}
+	if err := initializers.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&achievement).Error; err != nil {
+		log.Printf("Failed to unlock %s for user %d: %v", code, userID, err)
+		return achievement, false
+	}
+	return achievement, true
+}
+
+// count deleted completed tasks towards the clearing achievements
+func RecordTasksCleared(userID uint, count int) {
+	if count <= 0 {
+		return
+	}
+
+	stats, err := LoadStats(userID)
+	if err != nil {
+		log.Printf("Failed to load stats of user %d: %v", userID, err)
+		return
+	}
+
+	if err := initializers.DB.Model(&stats).Update("tasks_cleared", gorm.Expr("tasks_cleared + ?", count)).Error; err != nil {
+		log.Printf("Failed to count cleared tasks of user %d: %v", userID, err)
+		return
+	}
+
+	EmitAchievementEvent(userID, EventTasksCleared)
+}
+
+// achievement with the user progress
+type AchievementProgress struct {
+	Achievement
+	Progress   int        `json:"progress"`
+	Unlocked   bool       `json:"unlocked"`
+	UnlockedAt *time.Time `json:"unlockedAt"`
+}
+
+// every achievement with unlock state and current metric value
+func UserAchievements(userID uint) ([]AchievementProgress, error) {
+	unlocked, err := unlockedAchievements(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]int)
+	for metric, compute := range achievementMetrics {
+		if values[metric], err = compute(userID); err != nil {
+			return nil, err
+		}
+	}
+
+	result := make([]AchievementProgress, 0, len(Achievements))
+	for _, rule := range Achievements {
+		progress := AchievementProgress{Achievement: rule, Progress: min(values[rule.Metric], rule.Threshold)}
+
+		//reached before any event could unlock it, e.g. a streak record older than the rules
+		if _, ok := unlocked[rule.Code]; !ok && values[rule.Metric] >= rule.Threshold {
+			if achievement, ok := unlockAchievement(userID, rule.Code); ok {
+				unlocked[rule.Code] = achievement
+			}
+		}
+
+		if achievement, ok := unlocked[rule.Code]; ok {
+			unlockedAt := achievement.UnlockedAt
+			progress.Unlocked = true
+			progress.UnlockedAt = &unlockedAt
+			progress.Progress = rule.Threshold
+		}
+		result = append(result, progress)
+	}
+	return result, nil
+}
@@ -0,0 +1,129 @@
+package utils
+
+import (
+	"server/models"
+	"time"
+)
+
+const (
+	focusStatsDays   = 365
+	focusStatsWeeks  = 52
+	focusStatsMonths = 12
+)
+
+// completed pomodoros and focused minutes of one day, week or month
+type FocusBucket struct {
+	Date      string `json:"date"` //day, week start day or YYYY-MM
+	Pomodoros int    `json:"pomodoros"`
+	Minutes   int    `json:"minutes"`
+}
+
+type FocusStats struct {
+	TotalPomodoros        int           `json:"totalPomodoros"`
+	TotalMinutes          int           `json:"totalMinutes"`
+	AverageSessionMinutes float64       `json:"averageSessionMinutes"`
+	BestDay               *FocusBucket  `json:"bestDay"`
+	Days                  []FocusBucket `json:"days"` //heatmap of the last 365 days, oldest first
+	Weeks                 []FocusBucket `json:"weeks"`
+	Months                []FocusBucket `json:"months"`
+}
+
+// running sums of one bucket
+type focusSum struct {
+	pomodoros int
+	seconds   int
+}
+
+// first day of the month of t in loc
+func startOfMonth(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, loc)
+}
+
+// earliest moment the focus stats look at
+func FocusStatsSince(now time.Time, loc *time.Location) time.Time {
+	since := StartOfDay(now, loc).AddDate(0, 0, -(focusStatsDays - 1))
+	if week := StartOfWeek(now, loc).AddDate(0, 0, -7*(focusStatsWeeks-1)); week.Before(since) {
+		since = week
+	}
+	if month := startOfMonth(now, loc).AddDate(0, -(focusStatsMonths - 1), 0); month.Before(since) {
+		since = month
+	}
+	return since
+}
+
+// aggregate completed focus sessions into day, week and month buckets
+func BuildFocusStats(sessions []models.PomodoroSession, now time.Time, loc *time.Location) FocusStats {
+	days := make(map[string]*focusSum)
+	weeks := make(map[string]*focusSum)
+	months := make(map[string]*focusSum)
+
+	add := func(sums map[string]*focusSum, key string, seconds int) {
+		sum, ok := sums[key]
+		if !ok {
+			sum = &focusSum{}
+			sums[key] = sum
+		}
+		sum.pomodoros++
+		sum.seconds += seconds
+	}
+
+	dayFrom := StartOfDay(now, loc).AddDate(0, 0, -(focusStatsDays - 1))
+
+	var stats FocusStats
+	totalSeconds := 0
+	for _, session := range sessions {
+		if !session.Focus || !session.Completed {
+			continue
+		}
+
+		add(days, StartOfDay(session.EndedAt, loc).Format(time.DateOnly), session.ActualDuration)
+		add(weeks, StartOfWeek(session.EndedAt, loc).Format(time.DateOnly), session.ActualDuration)
+		add(months, startOfMonth(session.EndedAt, loc).Format("2006-01"), session.ActualDuration)
+
+		//totals cover the heatmap window
+		if !session.EndedAt.Before(dayFrom) {
+			stats.TotalPomodoros++
+			totalSeconds += session.ActualDuration
+		}
+	}
+
+	stats.TotalMinutes = totalSeconds / 60
+	if stats.TotalPomodoros > 0 {
+		stats.AverageSessionMinutes = float64(totalSeconds) / float64(stats.TotalPomodoros) / 60
+	}
+
+	bucket := func(sums map[string]*focusSum, key string) FocusBucket {
+		result := FocusBucket{Date: key}
+		if sum, ok := sums[key]; ok {
+			result.Pomodoros = sum.pomodoros
+			result.Minutes = sum.seconds / 60
+		}
+		return result
+	}
+
+	stats.Days = make([]FocusBucket, 0, focusStatsDays)
+	for i := 0; i < focusStatsDays; i++ {
+		day := bucket(days, dayFrom.AddDate(0, 0, i).Format(time.DateOnly))
+		stats.Days = append(stats.Days, day)
+
+		if day.Pomodoros > 0 && (stats.BestDay == nil || day.Minutes > stats.BestDay.Minutes) {
+			best := day
+			stats.BestDay = &best
+		}
+	}
+
+	weekFrom := StartOfWeek(now, loc).AddDate(0, 0, -7*(focusStatsWeeks-1))
+	stats.Weeks = make([]FocusBucket, 0, focusStatsWeeks)
+	for i := 0; i < focusStatsWeeks; i++ {
+		stats.Weeks = append(stats.Weeks, bucket(weeks, weekFrom.AddDate(0, 0, 7*i).Format(time.DateOnly)))
+	}
+
+	monthFrom := startOfMonth(now, loc).AddDate(0, -(focusStatsMonths - 1), 0)
+	stats.Months = make([]FocusBucket, 0, focusStatsMonths)
+	for i := 0; i < focusStatsMonths; i++ {
+		stats.Months = append(stats.Months, bucket(months, monthFrom.AddDate(0, i, 0).Format("2006-01")))
+	}
+
+	return stats
+}
@@ -0,0 +1,240 @@
+package utils
+
+import (
+	"bytes"
+	"fmt"
+	"server/models"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	icalTimeLayout  = "20060102T150405Z"
+	icalLocalLayout = "20060102T150405"
+	icalLineLimit   = 75 //octets per line before folding
+	icalZoneYears   = 10 //years of offset changes listed after the last start
+)
+
+// RFC 5545 priority of a task priority, 0 means undefined
+var icalPriorities = []int{0, 9, 5, 3, 1}
+
+// escape TEXT values
+func icalText(value string) string {
+	value = strings.ReplaceAll(value, "\\", "\\\\")
+	value = strings.ReplaceAll(value, ";", "\\;")
+	value = strings.ReplaceAll(value, ",", "\\,")
+	value = strings.ReplaceAll(value, "\r\n", "\\n")
+	return strings.ReplaceAll(value, "\n", "\\n")
+}
+
+func icalTime(t time.Time) string {
+	return t.UTC().Format(icalTimeLayout)
+}
+
+// time property in loc, recurrences expand in the zone of their start so weekdays and DST follow the user
+func icalZonedTime(name string, t time.Time, loc *time.Location) string {
+	if loc == time.UTC {
+		return name + ":" + icalTime(t)
+	}
+	return fmt.Sprintf("%s;TZID=%s:%s", name, loc.String(), t.In(loc).Format(icalLocalLayout))
+}
+
+// UTC offset as +HHMM, with seconds only when the zone has them
+func icalOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign, offset = "-", -offset
+	}
+	value := fmt.Sprintf("%s%02d%02d", sign, offset/3600, offset/60%60)
+	if offset%60 != 0 {
+		value += fmt.Sprintf("%02d", offset%60)
+	}
+	return value
+}
+
+// first moment after from where the offset of loc differs from the one at from, within a day
+func icalNextTransition(from time.Time, loc *time.Location) (time.Time, bool) {
+	_, offset := from.In(loc).Zone()
+	to := from.Add(24 * time.Hour)
+	if _, next := to.In(loc).Zone(); next == offset {
+		return to, false
+	}
+
+	//offsets change on whole seconds
+	for to.Sub(from) > time.Second {
+		mid := from.Add(to.Sub(from) / 2)
+		if _, current := mid.In(loc).Zone(); current == offset {
+			from = mid
+		} else {
+			to = mid
+		}
+	}
+	return to, true
+}
+
+// VTIMEZONE of loc with every offset change between from and until, RFC 5545 needs one for each TZID in use
+func writeICalTimezone(line func(format string, args ...interface{}), loc *time.Location, from time.Time, until time.Time) {
+	observance := func(at time.Time, offsetFrom int) {
+		local := at.In(loc)
+		name, offset := local.Zone()
+		kind := "STANDARD"
+		if local.IsDST() {
+			kind = "DAYLIGHT"
+		}
+		line("BEGIN:%s", kind)
+		//local time of the change on the clock before it
+		line("DTSTART:%s", at.UTC().Add(time.Duration(offsetFrom)*time.Second).Format(icalLocalLayout))
+		line("TZOFFSETFROM:%s", icalOffset(offsetFrom))
+		line("TZOFFSETTO:%s", icalOffset(offset))
+		line("TZNAME:%s", icalText(name))
+		line("END:%s", kind)
+	}
+
+	line("BEGIN:VTIMEZONE")
+	line("TZID:%s", loc.String())
+
+	_, offset := from.In(loc).Zone()
+	observance(from, offset)
+	for at := from; at.Before(until); {
+		next, changed := icalNextTransition(at, loc)
+		if changed {
+			observance(next, offset)
+			_, offset = next.In(loc).Zone()
+		}
+		at = next
+	}
+
+	line("END:VTIMEZONE")
+}
+
+// start of a recurring task, the beginning of its due day so it comes before DUE,
+// or the day before when the task is due at midnight
+func icalRecurrenceStart(due time.Time, loc *time.Location) time.Time {
+	start := StartOfDay(due, loc)
+	if !start.Before(due) {
+		start = StartOfDay(start.Add(-time.Second), loc)
+	}
+	return start
+}
+
+// RRULE value of a recurrence, the time of day comes from DTSTART and DUE instead,
+// a pinned BYHOUR would move every instance away from the start of its day
+func icalRecurrenceRule(recurrence string) (string, bool) {
+	rule, err := ParseRecurrence(recurrence)
+	if err != nil {
+		return "", false
+	}
+	rule.TimeOfDay = nil
+	return strings.TrimPrefix(rule.String(), "RRULE:"), true
+}
+
+// write a content line folded at 75 octets without splitting characters
+func writeICalLine(buf *bytes.Buffer, line string) {
+	limit := icalLineLimit
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		buf.WriteString(line[:cut])
+		buf.WriteString("\r\n ")
+		line = line[cut:]
+		//continuation lines start with the folding space
+		limit = icalLineLimit - 1
+	}
+	buf.WriteString(line)
+	buf.WriteString("\r\n")
+}
+
+// calendar with one VTODO per task, recurring tasks are written in the user timezone loc
+func BuildTaskCalendar(tasks []models.TasksModel, name string, host string, loc *time.Location) []byte {
+	var buf bytes.Buffer
+	line := func(format string, args ...interface{}) {
+		writeICalLine(&buf, fmt.Sprintf(format, args...))
+	}
+
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//Pomodoro//Tasks//EN")
+	line("CALSCALE:GREGORIAN")
+	line("X-WR-CALNAME:%s", icalText(name))
+
+	//recurring tasks are written in loc, its offsets are listed from the earliest start
+	if loc != time.UTC {
+		var from time.Time
+		for _, task := range tasks {
+			if _, ok := icalRecurrenceRule(task.Recurrence); ok && task.DueAt != nil {
+				start := icalRecurrenceStart(*task.DueAt, loc)
+				if from.IsZero() || start.Before(from) {
+					from = start
+				}
+			}
+		}
+		if !from.IsZero() {
+			from = time.Date(from.In(loc).Year(), time.January, 1, 0, 0, 0, 0, loc)
+			until := time.Now().AddDate(icalZoneYears, 0, 0)
+			writeICalTimezone(line, loc, from, until)
+		}
+	}
+
+	for _, task := range tasks {
+		line("BEGIN:VTODO")
+		line("UID:task-%d@%s", task.ID, host)
+		line("DTSTAMP:%s", icalTime(task.UpdatedAt))
+		line("CREATED:%s", icalTime(task.CreatedAt))
+		line("LAST-MODIFIED:%s", icalTime(task.UpdatedAt))
+		line("SUMMARY:%s", icalText(task.Title))
+		if task.Description != "" {
+			line("DESCRIPTION:%s", icalText(task.Description))
+		}
+
+		if task.Completed {
+			line("STATUS:COMPLETED")
+			line("PERCENT-COMPLETE:100")
+			//no completion time is stored, the last change is the closest one
+			line("COMPLETED:%s", icalTime(task.UpdatedAt))
+		} else {
+			line("STATUS:NEEDS-ACTION")
+			if len(task.Items) > 0 {
+				line("PERCENT-COMPLETE:%d", TaskProgress(task.Items))
+			}
+		}
+
+		if task.Priority > 0 && task.Priority < len(icalPriorities) {
+			line("PRIORITY:%d", icalPriorities[task.Priority])
+		}
+
+		if len(task.Tags) > 0 {
+			categories := make([]string, 0, len(task.Tags))
+			for _, tag := range task.Tags {
+				categories = append(categories, icalText(tag.Name))
+			}
+			line("CATEGORIES:%s", strings.Join(categories, ","))
+		}
+
+		if task.DueAt != nil {
+			//a recurrence needs a start strictly before DUE
+			if rule, ok := icalRecurrenceRule(task.Recurrence); ok {
+				writeICalLine(&buf, icalZonedTime("DTSTART", icalRecurrenceStart(*task.DueAt, loc), loc))
+				line("RRULE:%s", rule)
+				writeICalLine(&buf, icalZonedTime("DUE", *task.DueAt, loc))
+			} else {
+				line("DUE:%s", icalTime(*task.DueAt))
+			}
+
+			if task.ReminderOffset != nil && !task.Completed {
+				line("BEGIN:VALARM")
+				line("ACTION:DISPLAY")
+				line("DESCRIPTION:%s", icalText(task.Title))
+				line("TRIGGER;RELATED=END:-PT%dM", *task.ReminderOffset)
+				line("END:VALARM")
+			}
+		}
+
+		line("END:VTODO")
+	}
+
+	line("END:VCALENDAR")
+	return buf.Bytes()
+}
@@ -0,0 +1,47 @@
+package utils
+
+import (
+	"server/models"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCalendarTimezone(t *testing.T) {
+	newYork := mustLocation(t, "America/New_York")
+	due := time.Date(2024, time.June, 10, 9, 0, 0, 0, newYork)
+	calendar := string(BuildTaskCalendar([]models.TasksModel{
+		{Title: "Standup", DueAt: &due, Recurrence: "RRULE:FREQ=WEEKLY;BYDAY=MO;BYHOUR=9;BYMINUTE=0;BYSECOND=0"},
+	}, "Tasks", "example.com", newYork))
+
+	for _, want := range []string{
+		"BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\n",
+		//spring forward 2025 on the clock before the change
+		"BEGIN:DAYLIGHT\r\nDTSTART:20250309T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400\r\n",
+		"BEGIN:STANDARD\r\nDTSTART:20241103T020000\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500\r\n",
+		"DTSTART;TZID=America/New_York:20240610T000000\r\n",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO\r\n",
+		"DUE;TZID=America/New_York:20240610T090000\r\n",
+	} {
+		if !strings.Contains(calendar, want) {
+			t.Errorf("calendar misses %q", want)
+		}
+	}
+
+	if strings.Index(calendar, "END:VTIMEZONE") > strings.Index(calendar, "BEGIN:VTODO") {
+		t.Error("VTIMEZONE comes after the tasks using it")
+	}
+}
+
+func TestCalendarWithoutRecurrenceUsesUTC(t *testing.T) {
+	riga := mustLocation(t, "Europe/Riga")
+	due := time.Date(2024, time.March, 31, 12, 0, 0, 0, riga)
+	calendar := string(BuildTaskCalendar([]models.TasksModel{{Title: "Once", DueAt: &due}}, "Tasks", "example.com", riga))
+
+	if strings.Contains(calendar, "VTIMEZONE") || strings.Contains(calendar, "TZID=") {
+		t.Error("calendar without recurring tasks should only use UTC times")
+	}
+	if !strings.Contains(calendar, "DUE:20240331T090000Z\r\n") {
+		t.Error("due time is not written in UTC")
+	}
+}
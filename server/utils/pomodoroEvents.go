@@ -0,0 +1,175 @@
+package utils
+
+import (
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"log"
+	"server/models"
+	"sync"
+	"time"
+)
+
+const (
+	pomodoroPingPeriod = 30 * time.Second
+	pomodoroPongWait   = 60 * time.Second
+	pomodoroWriteWait  = 10 * time.Second
+)
+
+// timer event pushed to every open tab/device of a user
+type PomodoroEvent struct {
+	Event string `json:"event"`
+	State gin.H  `json:"state"`
+}
+
+// one open timer socket
+type pomodoroSocket struct {
+	ws     *websocket.Conn
+	send   chan []byte
+	userID uint
+}
+
+// open timer sockets grouped by user
+type pomodoroHub struct {
+	sockets map[uint]map[*pomodoroSocket]bool
+	mx      sync.Mutex
+}
+
+var timerHub = pomodoroHub{
+	sockets: make(map[uint]map[*pomodoroSocket]bool),
+}
+
+// timer state as seen by the client
+func PomodoroStatus(settings models.PomodoroModel) gin.H {
+	return gin.H{
+		"pomodoro":                settings.PomodoroDuration,
+		"shortBreak":              settings.ShortBreakDuration,
+		"longBreak":               settings.LongBreakDuration,
+		"longBreakInterval":       settings.LongBreakInterval,
+		"sequence":                settings.PhaseSequence,
+		"sequenceIndex":           settings.SequenceIndex,
+		"remainingTime":           RemainingTime(settings),
+		"phaseEndsAt":             settings.PhaseEndsAt,
+		"isRunning":               settings.IsRunning,
+		"state":                   TimerState(settings),
+		"pausedSeconds":           settings.PausedSeconds,
+		"currentPhase":            settings.CurrentPhase,
+		"completedPomodoros":      settings.CompletedPomodoros,
+		"totalCompletedPomodoros": settings.TotalCompletedPomodoros,
+		"autoTransition":          settings.AutoTransition,
+		"taskId":                  settings.TaskLocalID,
+		"serverTime":              time.Now(),
+	}
+}
+
+// push the timer state to every socket of the settings owner
+func BroadcastPomodoro(settings models.PomodoroModel, event string) {
+	data, err := json.Marshal(PomodoroEvent{Event: event, State: PomodoroStatus(settings)})
+	if err != nil {
+		log.Printf("Failed to marshal pomodoro event for user %d: %v", settings.UserID, err)
+		return
+	}
+
+	timerHub.mx.Lock()
+	defer timerHub.mx.Unlock()
+
+	for socket := range timerHub.sockets[settings.UserID] {
+		select {
+		case socket.send <- data:
+		default:
+			//slow client, drop it
+			timerHub.remove(socket)
+		}
+	}
+}
+
+// register an upgraded socket, send the current state and start its pumps
+func ServePomodoroSocket(ws *websocket.Conn, settings models.PomodoroModel) {
+	socket := &pomodoroSocket{
+		ws:     ws,
+		send:   make(chan []byte, 16),
+		userID: settings.UserID,
+	}
+
+	//new socket starts with the current state
+	if data, err := json.Marshal(PomodoroEvent{Event: "snapshot", State: PomodoroStatus(settings)}); err == nil {
+		socket.send <- data
+	}
+
+	timerHub.mx.Lock()
+	if timerHub.sockets[socket.userID] == nil {
+		timerHub.sockets[socket.userID] = make(map[*pomodoroSocket]bool)
+	}
+	timerHub.sockets[socket.userID][socket] = true
+	timerHub.mx.Unlock()
+
+	go socket.writePump()
+	go socket.readPump()
+}
+
+// must be called with hub mutex held
+func (h *pomodoroHub) remove(socket *pomodoroSocket) {
+	userSockets, ok := h.sockets[socket.userID]
+	if !ok || !userSockets[socket] {
+		return
+	}
+
+	delete(userSockets, socket)
+	if len(userSockets) == 0 {
+		delete(h.sockets, socket.userID)
+	}
+	close(socket.send)
+}
+
+// clients only listen, reading is needed to notice closed connections and pongs
+func (s *pomodoroSocket) readPump() {
+	defer func() {
+		timerHub.mx.Lock()
+		timerHub.remove(s)
+		timerHub.mx.Unlock()
+		s.ws.Close()
+	}()
+
+	s.ws.SetReadLimit(512)
+	s.ws.SetReadDeadline(time.Now().Add(pomodoroPongWait))
+	s.ws.SetPongHandler(func(string) error {
+		return s.ws.SetReadDeadline(time.Now().Add(pomodoroPongWait))
+	})
+
+	for {
+		if _, _, err := s.ws.ReadMessage(); err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
+				log.Printf("Pomodoro socket unexpected error for user %d: %v", s.userID, err)
+			}
+			return
+		}
+	}
+}
+
+func (s *pomodoroSocket) writePump() {
+	ticker := time.NewTicker(pomodoroPingPeriod)
+	defer func() {
+		ticker.Stop()
+		s.ws.Close()
+	}()
+
+	for {
+		select {
+		case data, ok := <-s.send:
+			s.ws.SetWriteDeadline(time.Now().Add(pomodoroWriteWait))
+			if !ok {
+				s.ws.WriteMessage(websocket.CloseMessage, []byte{})
+				return
+			}
+
+			if err := s.ws.WriteMessage(websocket.TextMessage, data); err != nil {
+				return
+			}
+		case <-ticker.C:
+			s.ws.SetWriteDeadline(time.Now().Add(pomodoroWriteWait))
+			if err := s.ws.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		}
+	}
+}
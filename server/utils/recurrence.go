@@ -0,0 +1,343 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// parsed recurrence rule, a subset of RFC 5545 RRULE
+type Recurrence struct {
+	Freq       string //DAILY, WEEKLY, MONTHLY or YEARLY
+	Interval   int
+	ByDay      []time.Weekday
+	ByMonth    time.Month
+	ByMonthDay int            //negative counts from the end of the month
+	TimeOfDay  *time.Duration //since midnight from BYHOUR, BYMINUTE and BYSECOND, keeps the time when a DST gap shifts one occurrence
+	Until      *time.Time
+}
+
+const (
+	maxRecurrenceLength = 255
+	maxRecurrenceSteps  = 1000 //bound when catching up with overdue occurrences
+)
+
+var rruleWeekdays = map[string]time.Weekday{
+	"MO": time.Monday,
+	"TU": time.Tuesday,
+	"WE": time.Wednesday,
+	"TH": time.Thursday,
+	"FR": time.Friday,
+	"SA": time.Saturday,
+	"SU": time.Sunday,
+}
+
+// turn a shorthand (daily, weekdays, weekly:MO,WE, monthly, yearly) or an RRULE into a canonical RRULE,
+// anchor gives the day a monthly or yearly rule repeats on
+func NormalizeRecurrence(input string, anchor time.Time) (string, error) {
+	input = strings.TrimSpace(input)
+	if len(input) > maxRecurrenceLength {
+		return "", errors.New("recurrence rule is too long")
+	}
+
+	var rule Recurrence
+	lower := strings.ToLower(input)
+	switch {
+	case lower == "daily":
+		rule = Recurrence{Freq: "DAILY", Interval: 1}
+	case lower == "weekdays":
+		rule = Recurrence{Freq: "WEEKLY", Interval: 1, ByDay: []time.Weekday{time.Monday, time.Tuesday, time.Wednesday, time.Thursday, time.Friday}}
+	case lower == "weekly":
+		rule = Recurrence{Freq: "WEEKLY", Interval: 1}
+	case strings.HasPrefix(lower, "weekly:"):
+		days, err := parseWeekdays(input[len("weekly:"):])
+		if err != nil {
+			return "", err
+		}
+		rule = Recurrence{Freq: "WEEKLY", Interval: 1, ByDay: days}
+	case lower == "monthly":
+		rule = Recurrence{Freq: "MONTHLY", Interval: 1}
+	case lower == "yearly":
+		rule = Recurrence{Freq: "YEARLY", Interval: 1}
+	default:
+		var err error
+		if rule, err = ParseRecurrence(input); err != nil {
+			return "", err
+		}
+	}
+
+	//pin the anchor day so month ends and leap days are not lost after clamping
+	if rule.TimeOfDay == nil {
+		rule.TimeOfDay = clockOf(anchor)
+	}
+	switch rule.Freq {
+	case "WEEKLY":
+		if len(rule.ByDay) == 0 {
+			rule.ByDay = []time.Weekday{anchor.Weekday()}
+		}
+	case "MONTHLY":
+		if rule.ByMonthDay == 0 {
+			rule.ByMonthDay = anchor.Day()
+		}
+	case "YEARLY":
+		if rule.ByMonth == 0 {
+			rule.ByMonth = anchor.Month()
+		}
+		if rule.ByMonthDay == 0 {
+			rule.ByMonthDay = anchor.Day()
+		}
+	}
+
+	return rule.String(), nil
+}
+
+// move the time of day of a canonical rule to the time of a new due date, invalid rules are returned as they are
+func PinRecurrenceTime(recurrence string, due time.Time) string {
+	rule, err := ParseRecurrence(recurrence)
+	if err != nil {
+		return recurrence
+	}
+	rule.TimeOfDay = clockOf(due)
+	return rule.String()
+}
+
+// time since midnight on the wall clock
+func clockOf(t time.Time) *time.Duration {
+	hour, minute, second := t.Clock()
+	clock := time.Duration(hour)*time.Hour + time.Duration(minute)*time.Minute + time.Duration(second)*time.Second
+	return &clock
+}
+
+// parse an RRULE, with or without the RRULE: prefix
+func ParseRecurrence(input string) (Recurrence, error) {
+	rule := Recurrence{Interval: 1}
+	body := strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(input)), "RRULE:")
+	if body == "" {
+		return rule, errors.New("empty recurrence rule")
+	}
+
+	//only one value per time part, occurrences are single points in time
+	clock := map[string]int{}
+	limits := map[string]int{"BYHOUR": 23, "BYMINUTE": 59, "BYSECOND": 59}
+
+	for _, part := range strings.Split(body, ";") {
+		name, value, ok := strings.Cut(part, "=")
+		if !ok || value == "" {
+			return rule, fmt.Errorf("malformed rule part %q", part)
+		}
+
+		switch name {
+		case "FREQ":
+			if value != "DAILY" && value != "WEEKLY" && value != "MONTHLY" && value != "YEARLY" {
+				return rule, fmt.Errorf("unsupported frequency %q", value)
+			}
+			rule.Freq = value
+		case "INTERVAL":
+			interval, err := strconv.Atoi(value)
+			if err != nil || interval < 1 || interval > 365 {
+				return rule, fmt.Errorf("invalid interval %q", value)
+			}
+			rule.Interval = interval
+		case "BYDAY":
+			days, err := parseWeekdays(value)
+			if err != nil {
+				return rule, err
+			}
+			rule.ByDay = days
+		case "BYMONTH":
+			month, err := strconv.Atoi(value)
+			if err != nil || month < 1 || month > 12 {
+				return rule, fmt.Errorf("invalid month %q", value)
+			}
+			rule.ByMonth = time.Month(month)
+		case "BYMONTHDAY":
+			day, err := strconv.Atoi(value)
+			if err != nil || day == 0 || day < -31 || day > 31 {
+				return rule, fmt.Errorf("invalid month day %q", value)
+			}
+			rule.ByMonthDay = day
+		case "BYHOUR", "BYMINUTE", "BYSECOND":
+			part, err := strconv.Atoi(value)
+			if err != nil || part < 0 || part > limits[name] {
+				return rule, fmt.Errorf("invalid %s %q", strings.ToLower(name[2:]), value)
+			}
+			clock[name] = part
+		case "UNTIL":
+			until, err := parseUntil(value)
+			if err != nil {
+				return rule, err
+			}
+			rule.Until = &until
+		default:
+			return rule, fmt.Errorf("unsupported rule part %q", name)
+		}
+	}
+
+	if rule.Freq == "" {
+		return rule, errors.New("recurrence rule needs FREQ")
+	}
+	if len(clock) > 0 {
+		timeOfDay := time.Duration(clock["BYHOUR"])*time.Hour + time.Duration(clock["BYMINUTE"])*time.Minute + time.Duration(clock["BYSECOND"])*time.Second
+		rule.TimeOfDay = &timeOfDay
+	}
+	if rule.ByMonth != 0 && rule.Freq != "YEARLY" {
+		return rule, errors.New("BYMONTH is only supported with FREQ=YEARLY")
+	}
+	if rule.ByMonthDay != 0 && rule.Freq != "MONTHLY" && rule.Freq != "YEARLY" {
+		return rule, errors.New("BYMONTHDAY is only supported with FREQ=MONTHLY or FREQ=YEARLY")
+	}
+	if len(rule.ByDay) > 0 && rule.Freq != "DAILY" && rule.Freq != "WEEKLY" {
+		return rule, errors.New("BYDAY is only supported with FREQ=DAILY or FREQ=WEEKLY")
+	}
+
+	return rule, nil
+}
+
+// comma separated two letter weekdays, sorted from monday
+func parseWeekdays(value string) ([]time.Weekday, error) {
+	var days []time.Weekday
+	for _, name := range strings.Split(strings.ToUpper(value), ",") {
+		day, ok := rruleWeekdays[strings.TrimSpace(name)]
+		if !ok {
+			return nil, fmt.Errorf("invalid weekday %q", name)
+		}
+		if !slices.Contains(days, day) {
+			days = append(days, day)
+		}
+	}
+
+	slices.SortFunc(days, func(a, b time.Weekday) int { return weekdayIndex(a) - weekdayIndex(b) })
+	return days, nil
+}
+
+// UNTIL as a date or an UTC date time
+func parseUntil(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102"} {
+		if until, err := time.Parse(layout, value); err == nil {
+			if layout == "20060102" {
+				//a date includes the whole day
+				until = until.Add(24*time.Hour - time.Second)
+			}
+			return until, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid UNTIL %q", value)
+}
+
+// days since monday
+func weekdayIndex(day time.Weekday) int {
+	return (int(day) + 6) % 7
+}
+
+// canonical RRULE text
+func (r Recurrence) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, "INTERVAL="+strconv.Itoa(r.Interval))
+	}
+	if len(r.ByDay) > 0 {
+		names := make([]string, 0, len(r.ByDay))
+		for _, day := range r.ByDay {
+			names = append(names, strings.ToUpper(day.String()[:2]))
+		}
+		parts = append(parts, "BYDAY="+strings.Join(names, ","))
+	}
+	if r.ByMonth != 0 {
+		parts = append(parts, "BYMONTH="+strconv.Itoa(int(r.ByMonth)))
+	}
+	if r.ByMonthDay != 0 {
+		parts = append(parts, "BYMONTHDAY="+strconv.Itoa(r.ByMonthDay))
+	}
+	if r.TimeOfDay != nil {
+		clock := *r.TimeOfDay
+		parts = append(parts,
+			"BYHOUR="+strconv.Itoa(int(clock/time.Hour)),
+			"BYMINUTE="+strconv.Itoa(int(clock%time.Hour/time.Minute)),
+			"BYSECOND="+strconv.Itoa(int(clock%time.Minute/time.Second)))
+	}
+	if r.Until != nil {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	return "RRULE:" + strings.Join(parts, ";")
+}
+
+// month day clamped to the length of the month, so the 31st falls on the last day of shorter months
+func clampMonthDay(year int, month time.Month, day int, loc *time.Location) int {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, loc).Day()
+	if day < 0 {
+		day = last + day + 1
+	}
+	return max(1, min(day, last))
+}
+
+// first occurrence strictly after current, at the time of day of the rule or else of current in loc
+func (r Recurrence) Next(current time.Time, loc *time.Location) time.Time {
+	current = current.In(loc)
+	year, month, day := current.Date()
+	hour, minute, second := current.Clock()
+	if r.TimeOfDay != nil {
+		clock := *r.TimeOfDay
+		hour, minute, second = int(clock/time.Hour), int(clock%time.Hour/time.Minute), int(clock%time.Minute/time.Second)
+	}
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hour, minute, second, 0, loc)
+	}
+
+	switch r.Freq {
+	case "WEEKLY":
+		//later day in the same week
+		for _, weekday := range r.ByDay {
+			if offset := weekdayIndex(weekday) - weekdayIndex(current.Weekday()); offset > 0 {
+				return at(year, month, day+offset)
+			}
+		}
+
+		//first day of the next week in the interval
+		monday := day - weekdayIndex(current.Weekday()) + 7*r.Interval
+		first := current.Weekday()
+		if len(r.ByDay) > 0 {
+			first = r.ByDay[0]
+		}
+		return at(year, month, monday+weekdayIndex(first))
+	case "MONTHLY":
+		target := time.Date(year, month+time.Month(r.Interval), 1, 0, 0, 0, 0, loc)
+		monthDay := r.ByMonthDay
+		if monthDay == 0 {
+			monthDay = day
+		}
+		return at(target.Year(), target.Month(), clampMonthDay(target.Year(), target.Month(), monthDay, loc))
+	case "YEARLY":
+		target := year + r.Interval
+		targetMonth := r.ByMonth
+		if targetMonth == 0 {
+			targetMonth = month
+		}
+		monthDay := r.ByMonthDay
+		if monthDay == 0 {
+			monthDay = day
+		}
+		return at(target, targetMonth, clampMonthDay(target, targetMonth, monthDay, loc))
+	default:
+		next := at(year, month, day+r.Interval)
+		for len(r.ByDay) > 0 && !slices.Contains(r.ByDay, next.Weekday()) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next
+	}
+}
+
+// next occurrence after current that is also after now, false when the rule ended
+func (r Recurrence) NextAfter(current, now time.Time, loc *time.Location) (time.Time, bool) {
+	next := r.Next(current, loc)
+	for i := 0; !next.After(now) && i < maxRecurrenceSteps; i++ {
+		next = r.Next(next, loc)
+	}
+
+	if r.Until != nil && next.After(*r.Until) {
+		return next, false
+	}
+	return next, true
+}
@@ -0,0 +1,119 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func mustLocation(t *testing.T, name string) *time.Location {
+	t.Helper()
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		t.Skipf("timezone data for %s is missing: %v", name, err)
+	}
+	return loc
+}
+
+// occurrences following start for a rule normalized against start
+func occurrences(t *testing.T, input string, start time.Time, count int) []time.Time {
+	t.Helper()
+
+	normalized, err := NormalizeRecurrence(input, start)
+	if err != nil {
+		t.Fatalf("normalize %q: %v", input, err)
+	}
+	rule, err := ParseRecurrence(normalized)
+	if err != nil {
+		t.Fatalf("parse %q: %v", normalized, err)
+	}
+
+	var result []time.Time
+	current := start
+	for i := 0; i < count; i++ {
+		current = rule.Next(current, start.Location())
+		result = append(result, current)
+	}
+	return result
+}
+
+func expectDates(t *testing.T, got []time.Time, want ...string) {
+	t.Helper()
+
+	if len(got) != len(want) {
+		t.Fatalf("got %d occurrences, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if date := got[i].Format(time.DateOnly); date != want[i] {
+			t.Errorf("occurrence %d is %s, want %s", i+1, date, want[i])
+		}
+	}
+}
+
+func TestRecurrenceMonthEnd(t *testing.T) {
+	start := time.Date(2024, time.January, 31, 9, 0, 0, 0, time.UTC)
+
+	expectDates(t, occurrences(t, "RRULE:FREQ=MONTHLY;BYMONTHDAY=31", start, 4),
+		"2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31")
+
+	//the pinned day survives a short month in a non leap year too
+	expectDates(t, occurrences(t, "monthly", start.AddDate(1, 0, 0), 3),
+		"2025-02-28", "2025-03-31", "2025-04-30")
+}
+
+func TestRecurrenceLastDayOfMonth(t *testing.T) {
+	start := time.Date(2023, time.December, 31, 9, 0, 0, 0, time.UTC)
+
+	expectDates(t, occurrences(t, "FREQ=MONTHLY;BYMONTHDAY=-1", start, 5),
+		"2024-01-31", "2024-02-29", "2024-03-31", "2024-04-30", "2024-05-31")
+}
+
+func TestRecurrenceLeapDay(t *testing.T) {
+	start := time.Date(2024, time.February, 29, 9, 0, 0, 0, time.UTC)
+
+	expectDates(t, occurrences(t, "yearly", start, 4),
+		"2025-02-28", "2026-02-28", "2027-02-28", "2028-02-29")
+}
+
+func TestRecurrenceWeekdays(t *testing.T) {
+	friday := time.Date(2024, time.March, 8, 9, 0, 0, 0, time.UTC)
+
+	expectDates(t, occurrences(t, "weekdays", friday, 3),
+		"2024-03-11", "2024-03-12", "2024-03-13")
+}
+
+func TestRecurrenceKeepsTimeAfterDSTGap(t *testing.T) {
+	riga := mustLocation(t, "Europe/Riga")
+	//clocks jump from 03:00 to 04:00 on 2024-03-31
+	start := time.Date(2024, time.March, 30, 3, 30, 0, 0, riga)
+
+	got := occurrences(t, "daily", start, 3)
+	want := []string{"2024-03-31 04:30", "2024-04-01 03:30", "2024-04-02 03:30"}
+	for i := range want {
+		if at := got[i].Format("2006-01-02 15:04"); at != want[i] {
+			t.Errorf("occurrence %d is %s, want %s", i+1, at, want[i])
+		}
+	}
+}
+
+func TestPinRecurrenceTime(t *testing.T) {
+	pinned := PinRecurrenceTime("RRULE:FREQ=DAILY;BYHOUR=9;BYMINUTE=0;BYSECOND=0", time.Date(2024, time.May, 1, 17, 45, 0, 0, time.UTC))
+	if want := "RRULE:FREQ=DAILY;BYHOUR=17;BYMINUTE=45;BYSECOND=0"; pinned != want {
+		t.Errorf("pinned rule %q, want %q", pinned, want)
+	}
+}
+
+func TestRecurrenceUntil(t *testing.T) {
+	rule, err := ParseRecurrence("FREQ=DAILY;UNTIL=20240305")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)
+	if next, ok := rule.NextAfter(time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC), now, time.UTC); !ok || next.Day() != 5 {
+		t.Errorf("last occurrence %v, %v, want March 5", next, ok)
+	}
+	if _, ok := rule.NextAfter(time.Date(2024, time.March, 5, 9, 0, 0, 0, time.UTC), now, time.UTC); ok {
+		t.Error("series went past UNTIL")
+	}
+}
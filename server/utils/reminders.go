@@ -0,0 +1,131 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+	"log"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"time"
+)
+
+// due filters of the task list
+const (
+	DueOverdue = "overdue"
+	DueToday   = "today"
+	DueWeek    = "week"
+)
+
+const (
+	maxReminderOffset   = 7 * 24 * 60 //minutes
+	reminderCheckPeriod = 30 * time.Second
+	reminderBatchSize   = 500
+)
+
+func IsValidDueFilter(filter string) bool {
+	return filter == DueOverdue || filter == DueToday || filter == DueWeek
+}
+
+// due_at bounds of a due filter in the user timezone, from is zero when open ended
+func DueRange(filter string, now time.Time, loc *time.Location) (from, to time.Time) {
+	switch filter {
+	case DueToday:
+		from = StartOfDay(now, loc)
+		return from, from.AddDate(0, 0, 1)
+	case DueWeek:
+		from = StartOfWeek(now, loc)
+		return from, from.AddDate(0, 0, 7)
+	default:
+		return time.Time{}, now
+	}
+}
+
+// reminder offset in minutes, only allowed together with a due date
+func IsValidReminderOffset(offset *int, dueAt *time.Time) bool {
+	if offset == nil {
+		return true
+	}
+	return dueAt != nil && *offset >= 0 && *offset <= maxReminderOffset
+}
+
+// set due date and reminder of a task, a moved reminder fires again
+func SetTaskDue(task *models.TasksModel, dueAt *time.Time, offset *int) {
+	if dueAt != nil {
+		due := dueAt.Truncate(time.Second)
+		dueAt = &due
+	} else {
+		offset = nil
+	}
+
+	var remindAt *time.Time
+	if offset != nil {
+		at := dueAt.Add(-time.Duration(*offset) * time.Minute)
+		remindAt = &at
+	}
+
+	if remindAt == nil || task.RemindAt == nil || !remindAt.Equal(*task.RemindAt) {
+		task.ReminderFiredAt = nil
+	}
+
+	task.DueAt = dueAt
+	task.ReminderOffset = offset
+	task.RemindAt = remindAt
+}
+
+// check for due reminders in the background
+func StartReminderWorker() {
+	go func() {
+		FireDueReminders(time.Now())
+
+		ticker := time.NewTicker(reminderCheckPeriod)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			FireDueReminders(now)
+		}
+	}()
+}
+
+// mark reminders of open tasks whose time has come as fired
+func FireDueReminders(now time.Time) {
+	for {
+		var tasks []models.TasksModel
+		err := initializers.DB.Select("id", "user_id").
+			Where("remind_at <= ? AND reminder_fired_at IS NULL AND completed = ?", now, false).
+			Limit(reminderBatchSize).
+			Find(&tasks).Error
+		if err != nil {
+			log.Printf("Failed to load due reminders: %v", err)
+			return
+		}
+		if len(tasks) == 0 {
+			return
+		}
+
+		ids := make([]uint, 0, len(tasks))
+		users := make(map[uint]bool)
+		for _, task := range tasks {
+			ids = append(ids, task.ID)
+			users[task.UserID] = true
+		}
+
+		err = initializers.DB.Transaction(func(tx *gorm.DB) error {
+			fired := tx.Model(&models.TasksModel{}).Where("id IN ?", ids).Session(&gorm.Session{})
+			if err := fired.Updates(map[string]interface{}{"reminder_fired_at": now, "version": BumpTaskVersion()}).Error; err != nil {
+				return err
+			}
+			return RecordTaskChanges(tx, TaskChangeUpsert, fired)
+		})
+		if err != nil {
+			log.Printf("Failed to fire reminders: %v", err)
+			return
+		}
+
+		for userID := range users {
+			cache.InvalidateUserTaskCaches(userID)
+		}
+
+		if len(tasks) < reminderBatchSize {
+			return
+		}
+	}
+}
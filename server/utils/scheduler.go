@@ -0,0 +1,126 @@
+package utils
+
+import (
+	"container/heap"
+	"sync"
+	"time"
+)
+
+// scheduled phase end of a single user timer
+type timerEntry struct {
+	userID   uint
+	deadline time.Time
+	index    int
+}
+
+// min-heap of timer entries ordered by deadline
+type timerQueue []*timerEntry
+
+func (q timerQueue) Len() int           { return len(q) }
+func (q timerQueue) Less(i, j int) bool { return q[i].deadline.Before(q[j].deadline) }
+
+func (q timerQueue) Swap(i, j int) {
+	q[i], q[j] = q[j], q[i]
+	q[i].index = i
+	q[j].index = j
+}
+
+func (q *timerQueue) Push(x any) {
+	entry := x.(*timerEntry)
+	entry.index = len(*q)
+	*q = append(*q, entry)
+}
+
+func (q *timerQueue) Pop() any {
+	old := *q
+	n := len(old)
+	entry := old[n-1]
+	old[n-1] = nil
+	entry.index = -1
+	*q = old[:n-1]
+	return entry
+}
+
+// single goroutine which fires phase ends for every running timer
+type timerScheduler struct {
+	mx      sync.Mutex
+	queue   timerQueue
+	entries map[uint]*timerEntry
+	wake    chan struct{}
+	once    sync.Once
+}
+
+var pomodoroScheduler = &timerScheduler{
+	entries: make(map[uint]*timerEntry),
+	wake:    make(chan struct{}, 1),
+}
+
+// add or move the deadline of a user timer
+func (s *timerScheduler) schedule(userID uint, deadline time.Time) {
+	s.once.Do(func() { go s.run() })
+
+	s.mx.Lock()
+	if entry, ok := s.entries[userID]; ok {
+		entry.deadline = deadline
+		heap.Fix(&s.queue, entry.index)
+	} else {
+		entry := &timerEntry{userID: userID, deadline: deadline}
+		heap.Push(&s.queue, entry)
+		s.entries[userID] = entry
+	}
+	s.mx.Unlock()
+
+	s.notify()
+}
+
+// remove a user timer from the queue
+func (s *timerScheduler) cancel(userID uint) {
+	s.mx.Lock()
+	if entry, ok := s.entries[userID]; ok {
+		heap.Remove(&s.queue, entry.index)
+		delete(s.entries, userID)
+	}
+	s.mx.Unlock()
+
+	s.notify()
+}
+
+// wake the run loop so it picks up a new earliest deadline
+func (s *timerScheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+func (s *timerScheduler) run() {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
+
+	for {
+		s.mx.Lock()
+		now := time.Now()
+		var due []*timerEntry
+		for len(s.queue) > 0 && !s.queue[0].deadline.After(now) {
+			entry := heap.Pop(&s.queue).(*timerEntry)
+			delete(s.entries, entry.userID)
+			due = append(due, entry)
+		}
+
+		wait := time.Hour
+		if len(s.queue) > 0 {
+			wait = time.Until(s.queue[0].deadline)
+		}
+		s.mx.Unlock()
+
+		for _, entry := range due {
+			go handlePhaseEnd(entry.userID, entry.deadline)
+		}
+
+		timer.Reset(wait)
+		select {
+		case <-timer.C:
+		case <-s.wake:
+		}
+	}
+}
@@ -0,0 +1,326 @@
+package utils
+
+import (
+	"html"
+	"math"
+	"server/models"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const searchSnippetRadius = 60 //bytes of context around the first description match
+
+// parsed search box input
+type SearchQuery struct {
+	Terms    []string   //every term must match
+	Phrases  [][]string //quoted, tokens must follow each other
+	Excluded []string   //-term, tasks containing it are dropped
+	Tags     []string   //tag:name
+	Done     *bool      //is:done or is:open
+	Priority *int       //priority:high
+	Project  string     //project:name or project:none
+}
+
+// ranked search result
+type SearchHit struct {
+	Task       models.TasksModel `json:"task"`
+	Score      float64           `json:"score"`
+	Highlights map[string]string `json:"highlights"` //html escaped text with <mark> around matches
+}
+
+// word of a text with its byte offsets
+type searchToken struct {
+	text       string
+	start, end int
+}
+
+// lowercase words of letters and digits
+func tokenize(text string) []searchToken {
+	var tokens []searchToken
+	start := -1
+	for i, r := range text {
+		isWord := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if isWord && start < 0 {
+			start = i
+		}
+		if !isWord && start >= 0 {
+			tokens = append(tokens, searchToken{text: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, searchToken{text: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+func tokenTexts(text string) []string {
+	var words []string
+	for _, token := range tokenize(text) {
+		words = append(words, token.text)
+	}
+	return words
+}
+
+// split on spaces outside of double quotes, quotes are kept
+func splitSearchInput(input string) []string {
+	var parts []string
+	var current strings.Builder
+	quoted := false
+	for _, r := range input {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			current.WriteRune(r)
+		case unicode.IsSpace(r) && !quoted:
+			if current.Len() > 0 {
+				parts = append(parts, current.String())
+				current.Reset()
+			}
+		default:
+			current.WriteRune(r)
+		}
+	}
+	if current.Len() > 0 {
+		parts = append(parts, current.String())
+	}
+	return parts
+}
+
+// parse terms, "quoted phrases", -exclusions and tag:, is:, priority:, project: operators
+func ParseSearchQuery(input string) SearchQuery {
+	var query SearchQuery
+	for _, part := range splitSearchInput(input) {
+		if name, value, ok := strings.Cut(part, ":"); ok && value != "" {
+			value = strings.Trim(value, `"`)
+			handled := true
+			switch strings.ToLower(name) {
+			case "tag":
+				query.Tags = append(query.Tags, NormalizeTag(value))
+			case "is":
+				switch strings.ToLower(value) {
+				case "done", "completed":
+					done := true
+					query.Done = &done
+				case "open", "todo":
+					done := false
+					query.Done = &done
+				default:
+					handled = false
+				}
+			case "priority":
+				if priority, ok := ParsePriority(strings.ToLower(value)); ok {
+					query.Priority = &priority
+				} else {
+					handled = false
+				}
+			case "project":
+				query.Project = value
+			default:
+				handled = false
+			}
+			if handled {
+				continue
+			}
+		}
+
+		if strings.HasPrefix(part, `"`) {
+			if phrase := tokenTexts(strings.Trim(part, `"`)); len(phrase) > 0 {
+				query.Phrases = append(query.Phrases, phrase)
+			}
+			continue
+		}
+
+		if strings.HasPrefix(part, "-") && len(part) > 1 {
+			query.Excluded = append(query.Excluded, tokenTexts(part[1:])...)
+			continue
+		}
+
+		query.Terms = append(query.Terms, tokenTexts(part)...)
+	}
+	return query
+}
+
+// whether the query has text to rank by
+func (q SearchQuery) HasText() bool {
+	return len(q.Terms) > 0 || len(q.Phrases) > 0
+}
+
+// tokens of one searchable field with the spans matched so far
+type searchField struct {
+	name   string
+	text   string
+	weight float64
+	tokens []searchToken
+	spans  [][2]int
+}
+
+// score of term in the field, exact words count more than prefixes
+func (f *searchField) matchTerm(term string) float64 {
+	score := 0.0
+	for _, token := range f.tokens {
+		switch {
+		case token.text == term:
+			score += 1
+		case strings.HasPrefix(token.text, term):
+			score += 0.5
+		default:
+			continue
+		}
+		f.spans = append(f.spans, [2]int{token.start, token.end})
+	}
+	return score * f.weight
+}
+
+// score of consecutive phrase tokens in the field
+func (f *searchField) matchPhrase(phrase []string) float64 {
+	score := 0.0
+	for i := 0; i+len(phrase) <= len(f.tokens); i++ {
+		matched := true
+		for j, word := range phrase {
+			if f.tokens[i+j].text != word {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			score += 2
+			f.spans = append(f.spans, [2]int{f.tokens[i].start, f.tokens[i+len(phrase)-1].end})
+		}
+	}
+	return score * f.weight
+}
+
+func (f *searchField) contains(word string) bool {
+	return slices.ContainsFunc(f.tokens, func(token searchToken) bool { return token.text == word })
+}
+
+// html escaped text with matched spans marked, long descriptions are cut around the first match
+func (f *searchField) highlight(snippet bool) string {
+	if len(f.spans) == 0 {
+		return ""
+	}
+
+	slices.SortFunc(f.spans, func(a, b [2]int) int { return a[0] - b[0] })
+
+	from, to := 0, len(f.text)
+	if snippet {
+		from = max(0, f.spans[0][0]-searchSnippetRadius)
+		to = min(len(f.text), f.spans[0][1]+searchSnippetRadius)
+		//keep cuts on rune boundaries
+		for from > 0 && !isRuneStart(f.text[from]) {
+			from--
+		}
+		for to < len(f.text) && !isRuneStart(f.text[to]) {
+			to++
+		}
+	}
+
+	var out strings.Builder
+	if from > 0 {
+		out.WriteString("…")
+	}
+	position := from
+	for _, span := range f.spans {
+		start, end := max(span[0], position), min(span[1], to)
+		if start >= end {
+			continue
+		}
+		out.WriteString(html.EscapeString(f.text[position:start]))
+		out.WriteString("<mark>")
+		out.WriteString(html.EscapeString(f.text[start:end]))
+		out.WriteString("</mark>")
+		position = end
+	}
+	out.WriteString(html.EscapeString(f.text[position:to]))
+	if to < len(f.text) {
+		out.WriteString("…")
+	}
+	return out.String()
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// rank tasks by the text of the query, every term and phrase has to match in the title or description,
+// rare terms weigh more than common ones
+func SearchTasks(tasks []models.TasksModel, query SearchQuery) []SearchHit {
+	docs := make([][]*searchField, len(tasks))
+	frequency := make(map[string]int)
+	for i, task := range tasks {
+		docs[i] = []*searchField{
+			{name: "title", text: task.Title, weight: 3, tokens: tokenize(task.Title)},
+			{name: "description", text: task.Description, weight: 1, tokens: tokenize(task.Description)},
+		}
+
+		seen := make(map[string]bool)
+		for _, field := range docs[i] {
+			for _, token := range field.tokens {
+				seen[token.text] = true
+			}
+		}
+		for word := range seen {
+			frequency[word]++
+		}
+	}
+
+	rarity := func(word string) float64 {
+		return math.Log(1 + float64(len(tasks))/float64(1+frequency[word]))
+	}
+
+	hits := make([]SearchHit, 0)
+	for i, task := range tasks {
+		fields := docs[i]
+
+		excluded := false
+		for _, word := range query.Excluded {
+			excluded = excluded || fields[0].contains(word) || fields[1].contains(word)
+		}
+		if excluded {
+			continue
+		}
+
+		score := 0.0
+		matchedAll := true
+		for _, term := range query.Terms {
+			termScore := fields[0].matchTerm(term) + fields[1].matchTerm(term)
+			matchedAll = matchedAll && termScore > 0
+			score += termScore * rarity(term)
+		}
+		for _, phrase := range query.Phrases {
+			phraseScore := fields[0].matchPhrase(phrase) + fields[1].matchPhrase(phrase)
+			matchedAll = matchedAll && phraseScore > 0
+			score += phraseScore * rarity(phrase[0])
+		}
+		if !matchedAll {
+			continue
+		}
+
+		highlights := make(map[string]string)
+		for _, field := range fields {
+			if marked := field.highlight(field.name == "description"); marked != "" {
+				highlights[field.name] = marked
+			}
+		}
+
+		hits = append(hits, SearchHit{Task: task, Score: math.Round(score*100) / 100, Highlights: highlights})
+	}
+
+	//open tasks first on equal score
+	slices.SortStableFunc(hits, func(a, b SearchHit) int {
+		switch {
+		case a.Score > b.Score:
+			return -1
+		case a.Score < b.Score:
+			return 1
+		case a.Task.Completed != b.Task.Completed && b.Task.Completed:
+			return -1
+		case a.Task.Completed != b.Task.Completed:
+			return 1
+		}
+		return 0
+	})
+	return hits
+}
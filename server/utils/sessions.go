@@ -0,0 +1,75 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+	"log"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"time"
+)
+
+// write the history row of the currently running phase
+func recordPomodoroSession(settings models.PomodoroModel, endedAt time.Time, completed bool) {
+	if settings.PhaseStartedAt == nil {
+		return
+	}
+
+	startedAt := *settings.PhaseStartedAt
+
+	//a run ended while paused also counts the current pause
+	paused := settings.PausedSeconds
+	if settings.PausedAt != nil {
+		paused += int(endedAt.Sub(*settings.PausedAt).Seconds())
+	}
+	actual := max(int(endedAt.Sub(startedAt).Seconds())-paused, 0)
+
+	session := models.PomodoroSession{
+		UserID:          settings.UserID,
+		Phase:           settings.CurrentPhase,
+		Focus:           IsFocusPhase(settings),
+		PlannedDuration: actual + remainingTimeAt(settings, endedAt),
+		ActualDuration:  actual,
+		PausedDuration:  paused,
+		StartedAt:       startedAt,
+		EndedAt:         endedAt,
+		Completed:       completed,
+		TaskLocalID:     settings.TaskLocalID,
+	}
+
+	if err := initializers.DB.Create(&session).Error; err != nil {
+		log.Printf("Failed to record pomodoro session of user %d: %v", settings.UserID, err)
+	}
+
+	if session.Focus && session.TaskLocalID != nil {
+		trackTaskFocus(session)
+	}
+
+	if session.Focus && session.Completed {
+		RecordActivity(session.UserID, ActivityPomodoroCompleted, endedAt)
+	}
+}
+
+// add focused time of a session to its linked task
+func trackTaskFocus(session models.PomodoroSession) {
+	updates := map[string]interface{}{
+		"focus_seconds": gorm.Expr("focus_seconds + ?", session.ActualDuration),
+	}
+	if session.Completed {
+		updates["pomodoro_count"] = gorm.Expr("pomodoro_count + 1")
+	}
+
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		task := tx.Model(&models.TasksModel{}).Where("local_id = ? AND user_id = ?", *session.TaskLocalID, session.UserID).Session(&gorm.Session{})
+		if err := task.Updates(updates).Error; err != nil {
+			return err
+		}
+		return RecordTaskChanges(tx, TaskChangeUpsert, task)
+	})
+	if err != nil {
+		log.Printf("Failed to track focus time of user %d: %v", session.UserID, err)
+		return
+	}
+
+	cache.InvalidateUserTaskCaches(session.UserID)
+}
@@ -0,0 +1,309 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"server/initializers"
+	"server/models"
+	"time"
+)
+
+// activity log counter columns
+const (
+	ActivityVisit             = "visits"
+	ActivityTaskCompleted     = "tasks_completed"
+	ActivityPomodoroCompleted = "pomodoros_completed"
+	ActivityChatMessage       = "chat_messages"
+)
+
+const (
+	freezeEarnDays  = 7 //qualifying days in a row that earn a freeze token
+	maxFreezeTokens = 2
+)
+
+// count an activity on the day of at in the user timezone, the streak is refreshed
+// only when this makes the day reach the rule of the activity
+func RecordActivity(userID uint, activity string, at time.Time) {
+	var user models.User
+	if err := initializers.DB.Select("id", "timezone").First(&user, userID).Error; err != nil {
+		log.Printf("Failed to load user %d for activity: %v", userID, err)
+		return
+	}
+	loc := UserLocation(user)
+
+	count, err := LogActivity(userID, activity, at, loc)
+	if err != nil {
+		log.Printf("Failed to record %s activity of user %d: %v", activity, userID, err)
+		return
+	}
+
+	stats, err := LoadStats(userID)
+	if err != nil {
+		log.Printf("Failed to load stats of user %d: %v", userID, err)
+		return
+	}
+	if threshold := activityThreshold(stats, activity); threshold == 0 || count != threshold {
+		return
+	}
+
+	if _, err := RecomputeStreak(userID, loc); err != nil {
+		log.Printf("Failed to recompute streak of user %d: %v", userID, err)
+	}
+}
+
+// minimum of an activity for a qualifying day, 0 when the activity does not count
+func activityThreshold(stats models.StatsModel, activity string) int {
+	switch activity {
+	case ActivityTaskCompleted:
+		return stats.MinTasksCompleted
+	case ActivityPomodoroCompleted:
+		return stats.MinPomodorosCompleted
+	case ActivityChatMessage:
+		return stats.MinChatMessages
+	}
+	return 0
+}
+
+// bump the activity counter of the day of at in loc, returns the counter of the day after the bump
+func LogActivity(userID uint, activity string, at time.Time, loc *time.Location) (int, error) {
+	entry := models.ActivityLog{
+		UserID: userID,
+		Day:    at.In(loc).Format(time.DateOnly),
+	}
+	switch activity {
+	case ActivityVisit:
+		entry.Visits = 1
+	case ActivityTaskCompleted:
+		entry.TasksCompleted = 1
+	case ActivityPomodoroCompleted:
+		entry.PomodorosCompleted = 1
+	case ActivityChatMessage:
+		entry.ChatMessages = 1
+	default:
+		return 0, fmt.Errorf("unknown activity %q", activity)
+	}
+
+	//insert the day or bump its counter
+	if err := initializers.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{activity: gorm.Expr(activity + " + 1")}),
+	}).Create(&entry).Error; err != nil {
+		return 0, err
+	}
+
+	var count int
+	err := initializers.DB.Model(&models.ActivityLog{}).
+		Where("user_id = ? AND day = ?", entry.UserID, entry.Day).
+		Select(activity).Scan(&count).Error
+	return count, err
+}
+
+// whether a logged day satisfies the streak rules
+func dayQualifies(day models.ActivityLog, stats models.StatsModel) bool {
+	return (stats.MinTasksCompleted > 0 && day.TasksCompleted >= stats.MinTasksCompleted) ||
+		(stats.MinPomodorosCompleted > 0 && day.PomodorosCompleted >= stats.MinPomodorosCompleted) ||
+		(stats.MinChatMessages > 0 && day.ChatMessages >= stats.MinChatMessages)
+}
+
+// walk the activity log day by day up to today and rebuild streak and freeze tokens
+func computeStreak(logs []models.ActivityLog, stats *models.StatsModel, today string) {
+	stats.CurrentStreak = 0
+	stats.FreezeTokens = 0
+	stats.FrozenDays = 0
+
+	byDay := make(map[string]models.ActivityLog, len(logs))
+	for _, entry := range logs {
+		byDay[entry.Day] = entry
+	}
+
+	//days of the streak counted before the log, they qualify like logged ones
+	legacy := make(map[string]bool)
+	var day time.Time
+	if end, err := time.Parse(time.DateOnly, stats.LegacyStreakEnd); err == nil && stats.LegacyStreak > 0 {
+		day = end.AddDate(0, 0, 1-stats.LegacyStreak)
+		for d := day; !d.After(end); d = d.AddDate(0, 0, 1) {
+			legacy[d.Format(time.DateOnly)] = true
+		}
+	}
+
+	//calendar days in UTC, so every step is exactly one day
+	if len(logs) > 0 {
+		first, err := time.Parse(time.DateOnly, logs[0].Day)
+		if err != nil {
+			return
+		}
+		if day.IsZero() || first.Before(day) {
+			day = first
+		}
+	}
+	if day.IsZero() {
+		return
+	}
+	last, err := time.Parse(time.DateOnly, today)
+	if err != nil {
+		return
+	}
+
+	earned := 0 //qualifying days in a row since the last earned token
+	for ; !day.After(last); day = day.AddDate(0, 0, 1) {
+		key := day.Format(time.DateOnly)
+
+		if legacy[key] || dayQualifies(byDay[key], *stats) {
+			stats.CurrentStreak++
+			earned++
+			if earned == freezeEarnDays {
+				earned = 0
+				stats.FreezeTokens = min(stats.FreezeTokens+1, maxFreezeTokens)
+			}
+
+			//the record never goes down, even if rules get stricter later
+			stats.HighestStreak = max(stats.HighestStreak, stats.CurrentStreak)
+			continue
+		}
+
+		//today is not over yet
+		if key == today {
+			continue
+		}
+
+		earned = 0
+		if stats.CurrentStreak > 0 && stats.FreezeTokens > 0 {
+			stats.FreezeTokens--
+			stats.FrozenDays++
+			continue
+		}
+
+		stats.CurrentStreak = 0
+		stats.FrozenDays = 0
+	}
+}
+
+// fill the activity log from completed pomodoros and tasks that predate it
+func seedActivityLog(userID uint, loc *time.Location) error {
+	days := make(map[string]*models.ActivityLog)
+	entry := func(at time.Time) *models.ActivityLog {
+		key := at.In(loc).Format(time.DateOnly)
+		if days[key] == nil {
+			days[key] = &models.ActivityLog{UserID: userID, Day: key}
+		}
+		return days[key]
+	}
+
+	var sessionEnds []time.Time
+	if err := initializers.DB.Model(&models.PomodoroSession{}).
+		Where("user_id = ? AND focus = ? AND completed = ?", userID, true, true).
+		Pluck("ended_at", &sessionEnds).Error; err != nil {
+		return err
+	}
+	for _, at := range sessionEnds {
+		entry(at).PomodorosCompleted++
+	}
+
+	//no completion time is stored, the last change is the closest one
+	var completedAt []time.Time
+	if err := initializers.DB.Unscoped().Model(&models.TasksModel{}).
+		Where("user_id = ? AND completed = ?", userID, true).
+		Pluck("updated_at", &completedAt).Error; err != nil {
+		return err
+	}
+	for _, at := range completedAt {
+		entry(at).TasksCompleted++
+	}
+
+	if len(days) == 0 {
+		return nil
+	}
+	logs := make([]models.ActivityLog, 0, len(days))
+	for _, day := range days {
+		logs = append(logs, *day)
+	}
+
+	//days logged live already count the same activity, keep the larger count
+	return initializers.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "day"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			ActivityTaskCompleted:     gorm.Expr("GREATEST(tasks_completed, VALUES(tasks_completed))"),
+			ActivityPomodoroCompleted: gorm.Expr("GREATEST(pomodoros_completed, VALUES(pomodoros_completed))"),
+		}),
+	}).CreateInBatches(&logs, 500).Error
+}
+
+// stats row of the user, created on first use
+func LoadStats(userID uint) (models.StatsModel, error) {
+	var stats models.StatsModel
+	err := initializers.DB.Where("user_id = ?", userID).First(&stats).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		stats = models.StatsModel{
+			UserID:         userID,
+			LastVisitDate:  time.Now(),
+			TotalVisitDays: 1,
+		}
+		err = initializers.DB.Create(&stats).Error
+	}
+	return stats, err
+}
+
+// streak rebuilt from the activity log without saving anything, for read only paths.
+// a log that was never seeded does not cover the stored streak yet, so that one is returned
+func StreakSnapshot(userID uint, loc *time.Location) (models.StatsModel, error) {
+	var stats models.StatsModel
+	if err := initializers.DB.Where("user_id = ?", userID).Limit(1).Find(&stats).Error; err != nil {
+		return stats, err
+	}
+	if stats.ID == 0 {
+		//defaults of a stats row that is created on the first write
+		return models.StatsModel{UserID: userID, MinTasksCompleted: 1, MinPomodorosCompleted: 1, MinChatMessages: 1}, nil
+	}
+	if !stats.ActivitySeeded {
+		return stats, nil
+	}
+
+	var logs []models.ActivityLog
+	if err := initializers.DB.Where("user_id = ?", userID).Order("day asc").Find(&logs).Error; err != nil {
+		return stats, err
+	}
+
+	computeStreak(logs, &stats, time.Now().In(loc).Format(time.DateOnly))
+	return stats, nil
+}
+
+// rebuild the streak of a user from the activity log and save it
+func RecomputeStreak(userID uint, loc *time.Location) (models.StatsModel, error) {
+	stats, err := LoadStats(userID)
+	if err != nil {
+		return stats, err
+	}
+
+	if !stats.ActivitySeeded {
+		if err := seedActivityLog(userID, loc); err != nil {
+			return stats, err
+		}
+
+		//the visit streak stored before the log is kept until logged days take over
+		stats.ActivitySeeded = true
+		if stats.CurrentStreak > 0 && stats.LegacyStreakEnd == "" {
+			stats.LegacyStreak = stats.CurrentStreak
+			stats.LegacyStreakEnd = stats.LastVisitDate.In(loc).Format(time.DateOnly)
+		}
+		if err := initializers.DB.Model(&stats).Select("activity_seeded", "legacy_streak", "legacy_streak_end").Updates(&stats).Error; err != nil {
+			return stats, err
+		}
+	}
+
+	var logs []models.ActivityLog
+	if err := initializers.DB.Where("user_id = ?", userID).Order("day asc").Find(&logs).Error; err != nil {
+		return stats, err
+	}
+
+	computeStreak(logs, &stats, time.Now().In(loc).Format(time.DateOnly))
+
+	//rules are checked every time, a record reached before they existed unlocks too
+	err = initializers.DB.Model(&stats).Select("current_streak", "highest_streak", "freeze_tokens", "frozen_days").Updates(&stats).Error
+	if err == nil {
+		go EmitAchievementEvent(userID, EventStreakUpdated)
+	}
+	return stats, err
+}
@@ -0,0 +1,20 @@
+package utils
+
+import "strings"
+
+const MaxTaskTags = 10
+
+// tag names are lowercase without the leading # and with single spaces
+func NormalizeTag(name string) string {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "#")
+	return strings.ToLower(strings.Join(strings.Fields(name), " "))
+}
+
+func IsValidTag(name string) bool {
+	return len(name) >= 1 && len(name) <= 30 && !strings.Contains(name, ",")
+}
+
+func IsValidProjectName(name string) bool {
+	name = strings.TrimSpace(name)
+	return len(name) >= 1 && len(name) <= 50
+}
@@ -0,0 +1,135 @@
+package utils
+
+import (
+	"database/sql"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"server/initializers"
+	"server/models"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	TaskChangeUpsert = "upsert"
+	TaskChangeDelete = "delete"
+
+	TaskChangeRetentionDays = 90
+	syncOperationKey        = "sync:operation"
+	sequenceBatchSize       = 1000
+)
+
+// changes written through the returned db are tagged with the client operation id
+func WithSyncOperation(db *gorm.DB, opID string) *gorm.DB {
+	return db.Set(syncOperationKey, opID).Session(&gorm.Session{})
+}
+
+func syncOperationID(db *gorm.DB) string {
+	if value, ok := db.Get(syncOperationKey); ok {
+		return value.(string)
+	}
+	return ""
+}
+
+// log a change of one task
+func RecordTaskChange(db *gorm.DB, task models.TasksModel, op string) error {
+	return db.Create(&models.TaskChange{
+		UserID:  task.UserID,
+		TaskID:  task.ID,
+		LocalID: task.LocalID,
+		Op:      op,
+		OpID:    syncOperationID(db),
+	}).Error
+}
+
+// log a change of every task selected by the query, for bulk updates
+func RecordTaskChanges(db *gorm.DB, op string, tasks *gorm.DB) error {
+	now := time.Now()
+	return db.Exec(
+		"INSERT INTO task_changes (created_at, updated_at, user_id, task_id, local_id, op, op_id) ?",
+		tasks.Select("?, ?, user_id, id, local_id, ?, ?", now, now, op, syncOperationID(db)),
+	).Error
+}
+
+// drop changes older than the retention, clients behind it get a full snapshot
+func PurgeTaskChanges(now time.Time) {
+	before := now.AddDate(0, 0, -TaskChangeRetentionDays)
+	if err := initializers.DB.Unscoped().Where("created_at < ?", before).Delete(&models.TaskChange{}).Error; err != nil {
+		log.Printf("Failed to purge task changes: %v", err)
+	}
+}
+
+// number the committed changes of the user in the order they became visible and return the last number.
+// change ids are taken at insert, so a slow transaction can commit an id below one already served,
+// the sequence is only given once a change is committed and never goes backwards
+func SequenceTaskChanges(userID uint) (uint, error) {
+	var seq uint
+	err := initializers.DB.Transaction(func(tx *gorm.DB) error {
+		counter := models.TaskChangeSequence{UserID: userID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counter).Error; err != nil {
+			return err
+		}
+
+		//one numbering run per user at a time
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&counter).Error; err != nil {
+			return err
+		}
+		seq = counter.Seq
+
+		for {
+			//read committed skips rows of open transactions, a later run numbers them
+			var ids []uint
+			if err := tx.Unscoped().Model(&models.TaskChange{}).
+				Where("user_id = ? AND seq = 0", userID).
+				Order("id asc").
+				Limit(sequenceBatchSize).
+				Pluck("id", &ids).Error; err != nil {
+				return err
+			}
+			if len(ids) == 0 {
+				break
+			}
+
+			list := make([]string, 0, len(ids))
+			for _, id := range ids {
+				list = append(list, strconv.FormatUint(uint64(id), 10))
+			}
+			if err := tx.Unscoped().Model(&models.TaskChange{}).
+				Where("id IN ?", ids).
+				Update("seq", gorm.Expr("? + FIND_IN_SET(id, ?)", seq, strings.Join(list, ","))).Error; err != nil {
+				return err
+			}
+			seq += uint(len(ids))
+
+			if len(ids) < sequenceBatchSize {
+				break
+			}
+		}
+
+		return tx.Model(&counter).Update("seq", seq).Error
+	}, &sql.TxOptions{Isolation: sql.LevelReadCommitted})
+	return seq, err
+}
+
+// false when changes after the cursor may already be purged or the cursor was never given
+func TaskChangeCursorAvailable(userID uint, cursor uint, latest uint) (bool, error) {
+	if cursor == 0 || cursor > latest {
+		return false, nil
+	}
+
+	var oldest uint
+	if err := initializers.DB.Model(&models.TaskChange{}).
+		Where("user_id = ? AND seq > 0", userID).
+		Select("COALESCE(MIN(seq), 0)").
+		Scan(&oldest).Error; err != nil {
+		return false, err
+	}
+
+	//everything purged, only a client that saw the last change is up to date
+	if oldest == 0 {
+		return cursor == latest, nil
+	}
+	return cursor+1 >= oldest, nil
+}
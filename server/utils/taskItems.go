@@ -0,0 +1,18 @@
+package utils
+
+import "server/models"
+
+// percent of completed checklist items, 0 for tasks without items
+func TaskProgress(items []models.TaskItem) int {
+	if len(items) == 0 {
+		return 0
+	}
+
+	completed := 0
+	for _, item := range items {
+		if item.Completed {
+			completed++
+		}
+	}
+	return completed * 100 / len(items)
+}
@@ -0,0 +1,92 @@
+package utils
+
+import (
+	"server/models"
+	"slices"
+	"time"
+)
+
+// task priorities from lowest to highest, stored as their index
+var TaskPriorities = []string{"none", "low", "medium", "high", "urgent"}
+
+// task list sort modes
+const (
+	SortManual   = "manual" //drag order from UpdateTasksOrder
+	SortPriority = "priority"
+	SortDue      = "due"
+	SortCreated  = "created"
+	SortSmart    = "smart"
+)
+
+// sql order of every mode, smart is ranked in go on top of the manual order
+var taskSortOrders = map[string]string{
+	SortManual:   "`order` asc",
+	SortPriority: "priority desc, `order` asc",
+	SortDue:      "due_at IS NULL, due_at asc, `order` asc",
+	SortCreated:  "created_at desc",
+	SortSmart:    "`order` asc",
+}
+
+// priority value of a name, false when unknown
+func ParsePriority(name string) (int, bool) {
+	priority := slices.Index(TaskPriorities, name)
+	return priority, priority >= 0
+}
+
+func IsValidTaskSort(mode string) bool {
+	_, ok := taskSortOrders[mode]
+	return ok
+}
+
+// sql order clause of a sort mode
+func TaskSortOrder(mode string) string {
+	if order, ok := taskSortOrders[mode]; ok {
+		return order
+	}
+	return taskSortOrders[SortManual]
+}
+
+// urgency of a task combining priority, due date and age, higher comes first
+func smartScore(task models.TasksModel, now time.Time) float64 {
+	score := float64(task.Priority) * 10
+
+	if task.DueAt != nil {
+		untilDue := task.DueAt.Sub(now)
+		switch {
+		case untilDue < 0:
+			score += 30 + min(-untilDue.Hours()/24, 10)
+		case untilDue < 24*time.Hour:
+			score += 25
+		case untilDue < 3*24*time.Hour:
+			score += 15
+		case untilDue < 7*24*time.Hour:
+			score += 8
+		}
+	}
+
+	//old tasks slowly bubble up
+	score += min(now.Sub(task.CreatedAt).Hours()/24, 14) / 2
+
+	return score
+}
+
+// rank open tasks by smart score, completed tasks stay at the end, stable on the manual order
+func SmartSortTasks(tasks []models.TasksModel, now time.Time) {
+	slices.SortStableFunc(tasks, func(a, b models.TasksModel) int {
+		if a.Completed != b.Completed {
+			if a.Completed {
+				return 1
+			}
+			return -1
+		}
+
+		scoreA, scoreB := smartScore(a, now), smartScore(b, now)
+		switch {
+		case scoreA > scoreB:
+			return -1
+		case scoreA < scoreB:
+			return 1
+		}
+		return 0
+	})
+}
@@ -0,0 +1,329 @@
+package utils
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// import and export formats
+const (
+	TransferJSON     = "json"
+	TransferCSV      = "csv"
+	TransferMarkdown = "md"
+)
+
+const MaxImportRows = 1000
+
+// task as it leaves or enters the system, independent of ids
+type TransferTask struct {
+	Title       string     `json:"title"`
+	Description string     `json:"description"`
+	Completed   bool       `json:"completed"`
+	Order       int        `json:"order"`
+	Priority    string     `json:"priority,omitempty"`
+	DueAt       *time.Time `json:"dueAt,omitempty"`
+	Project     string     `json:"project,omitempty"`
+	Tags        []string   `json:"tags,omitempty"`
+}
+
+// parsed row of an import file, Error is set when the row could not be read
+type ImportRow struct {
+	Row   int          `json:"row"`
+	Task  TransferTask `json:"task"`
+	Error string       `json:"error,omitempty"`
+}
+
+var csvHeader = []string{"title", "description", "completed", "order", "priority", "due_at", "project", "tags"}
+
+func IsValidTransferFormat(format string) bool {
+	return format == TransferJSON || format == TransferCSV || format == TransferMarkdown
+}
+
+// encode tasks, returns the file and its content type
+func ExportTasks(format string, tasks []TransferTask) ([]byte, string, error) {
+	switch format {
+	case TransferJSON:
+		data, err := json.MarshalIndent(struct {
+			Tasks []TransferTask `json:"tasks"`
+		}{tasks}, "", "  ")
+		return data, "application/json; charset=utf-8", err
+	case TransferCSV:
+		data, err := exportCSV(tasks)
+		return data, "text/csv; charset=utf-8", err
+	case TransferMarkdown:
+		return exportMarkdown(tasks), "text/markdown; charset=utf-8", nil
+	}
+	return nil, "", fmt.Errorf("unknown format %q", format)
+}
+
+// decode an import file into rows, an error means the whole file is unreadable
+func ParseTasks(format string, data []byte) ([]ImportRow, error) {
+	switch format {
+	case TransferJSON:
+		return parseJSON(data)
+	case TransferCSV:
+		return parseCSV(data)
+	case TransferMarkdown:
+		return parseMarkdown(data)
+	}
+	return nil, fmt.Errorf("unknown format %q", format)
+}
+
+func exportCSV(tasks []TransferTask) ([]byte, error) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+	if err := writer.Write(csvHeader); err != nil {
+		return nil, err
+	}
+
+	for _, task := range tasks {
+		dueAt := ""
+		if task.DueAt != nil {
+			dueAt = task.DueAt.UTC().Format(time.RFC3339)
+		}
+		record := []string{
+			task.Title,
+			task.Description,
+			strconv.FormatBool(task.Completed),
+			strconv.Itoa(task.Order),
+			task.Priority,
+			dueAt,
+			task.Project,
+			strings.Join(task.Tags, ","),
+		}
+		if err := writer.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+// checklist grouped by project headings, descriptions are indented under their task
+func exportMarkdown(tasks []TransferTask) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Tasks\n")
+
+	project := ""
+	first := true
+	for _, task := range tasks {
+		if task.Project != project || first {
+			if task.Project != "" {
+				fmt.Fprintf(&buf, "\n## %s\n", task.Project)
+			}
+			buf.WriteString("\n")
+			project = task.Project
+			first = false
+		}
+
+		mark := " "
+		if task.Completed {
+			mark = "x"
+		}
+		fmt.Fprintf(&buf, "- [%s] %s\n", mark, strings.TrimSpace(task.Title))
+
+		if task.Description != "" {
+			for _, line := range strings.Split(task.Description, "\n") {
+				fmt.Fprintf(&buf, "  %s\n", line)
+			}
+		}
+	}
+	return buf.Bytes()
+}
+
+// array of tasks or an object with a tasks array
+func parseJSON(data []byte) ([]ImportRow, error) {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		var wrapped struct {
+			Tasks []json.RawMessage `json:"tasks"`
+		}
+		if err := json.Unmarshal(data, &wrapped); err != nil {
+			return nil, errors.New("file is not a json array of tasks")
+		}
+		raw = wrapped.Tasks
+	}
+
+	rows := make([]ImportRow, 0, len(raw))
+	for i, item := range raw {
+		row := ImportRow{Row: i + 1}
+		if err := json.Unmarshal(item, &row.Task); err != nil {
+			row.Error = "invalid task: " + err.Error()
+		}
+		if row.Task.Order == 0 {
+			row.Task.Order = i + 1
+		}
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// csv with a header row, columns are matched by name and only title is required
+func parseCSV(data []byte) ([]ImportRow, error) {
+	reader := csv.NewReader(bytes.NewReader(data))
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, errors.New("csv file has no header row")
+	}
+	columns := make(map[string]int)
+	for i, name := range header {
+		columns[strings.ToLower(strings.TrimSpace(strings.TrimPrefix(name, "\ufeff")))] = i
+	}
+	if _, ok := columns["title"]; !ok {
+		return nil, errors.New("csv header has no title column")
+	}
+
+	var rows []ImportRow
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("csv %v", err)
+		}
+		//quoted fields may span lines, so the row is the line the record starts on
+		line, _ := reader.FieldPos(0)
+
+		field := func(name string) string {
+			if i, ok := columns[name]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		row := ImportRow{Row: line, Task: TransferTask{
+			Title:       field("title"),
+			Description: field("description"),
+			Priority:    strings.ToLower(field("priority")),
+			Project:     field("project"),
+			Order:       len(rows) + 1,
+		}}
+
+		if tags := field("tags"); tags != "" {
+			row.Task.Tags = strings.Split(tags, ",")
+		}
+
+		if value := field("completed"); value != "" {
+			completed, ok := parseTransferBool(value)
+			if !ok {
+				row.Error = fmt.Sprintf("completed must be true or false, got %q", value)
+			}
+			row.Task.Completed = completed
+		}
+
+		if value := field("order"); value != "" && row.Error == "" {
+			order, err := strconv.Atoi(value)
+			if err != nil {
+				row.Error = fmt.Sprintf("order must be a number, got %q", value)
+			}
+			row.Task.Order = order
+		}
+
+		if value := field("due_at"); value != "" && row.Error == "" {
+			dueAt, err := ParseTransferTime(value)
+			if err != nil {
+				row.Error = fmt.Sprintf("due_at must be a date, got %q", value)
+			}
+			row.Task.DueAt = dueAt
+		}
+
+		rows = append(rows, row)
+	}
+	return rows, nil
+}
+
+// "- [ ] title" lines with indented description lines, "## name" headings set the project
+func parseMarkdown(data []byte) ([]ImportRow, error) {
+	var rows []ImportRow
+	project := ""
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+
+	for line := 1; scanner.Scan(); line++ {
+		text := scanner.Text()
+		trimmed := strings.TrimSpace(text)
+		indented := strings.HasPrefix(text, "  ") || strings.HasPrefix(text, "\t")
+
+		//an indented heading belongs to a description
+		if strings.HasPrefix(trimmed, "## ") && !indented {
+			project = strings.TrimSpace(trimmed[3:])
+			continue
+		}
+
+		if title, completed, ok := parseChecklistLine(trimmed); ok && !indented {
+			rows = append(rows, ImportRow{Row: line, Task: TransferTask{
+				Title:     title,
+				Completed: completed,
+				Order:     len(rows) + 1,
+				Project:   project,
+			}})
+			continue
+		}
+
+		//indented lines continue the description of the last task
+		if len(rows) > 0 && indented {
+			last := &rows[len(rows)-1]
+			line := strings.TrimPrefix(strings.TrimPrefix(text, "\t"), "  ")
+			if last.Task.Description != "" {
+				last.Task.Description += "\n"
+			}
+			last.Task.Description += line
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// title and state of a "- [ ]", "- [x]" or "* [x]" line
+func parseChecklistLine(line string) (string, bool, bool) {
+	for _, bullet := range []string{"- ", "* ", "+ "} {
+		if !strings.HasPrefix(line, bullet) {
+			continue
+		}
+		rest := line[len(bullet):]
+		switch {
+		case strings.HasPrefix(rest, "[ ]"):
+			return strings.TrimSpace(rest[3:]), false, true
+		case strings.HasPrefix(rest, "[x]"), strings.HasPrefix(rest, "[X]"):
+			return strings.TrimSpace(rest[3:]), true, true
+		}
+	}
+	return "", false, false
+}
+
+func parseTransferBool(value string) (bool, bool) {
+	value = strings.ToLower(value)
+	switch {
+	case slices.Contains([]string{"true", "1", "yes", "x", "done"}, value):
+		return true, true
+	case slices.Contains([]string{"false", "0", "no", "", "open"}, value):
+		return false, true
+	}
+	return false, false
+}
+
+// RFC 3339 time or a plain date at midnight UTC
+func ParseTransferTime(value string) (*time.Time, error) {
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04:05", time.DateTime, time.DateOnly} {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return &parsed, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid time %q", value)
+}
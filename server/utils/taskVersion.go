@@ -0,0 +1,72 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"server/models"
+	"strings"
+)
+
+// the task row changed after it was read
+var ErrTaskVersionConflict = errors.New("task was changed by another client")
+
+// strong etag of the current task version
+func TaskETag(task models.TasksModel) string {
+	return fmt.Sprintf("\"task-%d-v%d\"", task.LocalID, task.Version)
+}
+
+// true when the If-Match header is missing, "*" or lists the current etag of the task
+func TaskMatches(ifMatch string, task models.TasksModel) bool {
+	ifMatch = strings.TrimSpace(ifMatch)
+	if ifMatch == "" || ifMatch == "*" {
+		return true
+	}
+
+	current := TaskETag(task)
+	for _, tag := range strings.Split(ifMatch, ",") {
+		//weak tags compare by value, the version is the same either way
+		if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// version bump for bulk updates that do not check a version
+func BumpTaskVersion() interface{} {
+	return gorm.Expr("version + 1")
+}
+
+// update only the given columns when the row still has the version the task was read with,
+// bumps the version, reloads the task and logs the change
+func UpdateTaskFields(db *gorm.DB, task *models.TasksModel, fields map[string]interface{}) error {
+	fields["version"] = BumpTaskVersion()
+
+	result := db.Model(&models.TasksModel{}).
+		Where("id = ? AND version = ?", task.ID, task.Version).
+		Updates(fields)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTaskVersionConflict
+	}
+
+	if err := db.First(task, task.ID).Error; err != nil {
+		return err
+	}
+	return RecordTaskChange(db, *task, TaskChangeUpsert)
+}
+
+// soft delete the task when the row still has the version it was read with and log the change
+func DeleteTaskVersion(db *gorm.DB, task *models.TasksModel) error {
+	result := db.Where("version = ?", task.Version).Delete(task)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrTaskVersionConflict
+	}
+	return RecordTaskChange(db, *task, TaskChangeDelete)
+}
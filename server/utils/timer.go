@@ -1,95 +1,402 @@
 package utils
 
 import (
+	"log"
 	"server/cache"
 	"server/initializers"
+	"server/models"
+	"slices"
 	"sync"
 	"time"
 )
 
-var timerMutex sync.Mutex
+// per user locks, so handlers and the scheduler never update the same timer row at once
+var timerLocks sync.Map
 
-func StartPomodoroTimer(userID uint) {
-	timerMutex.Lock()
-	defer timerMutex.Unlock()
+// lock the timer of one user, returns the unlock func
+func LockPomodoro(userID uint) func() {
+	mx, _ := timerLocks.LoadOrStore(userID, &sync.Mutex{})
+	lock := mx.(*sync.Mutex)
+	lock.Lock()
+	return lock.Unlock
+}
+
+// step of the custom sequence the timer is on, nil in classic mode
+func currentStep(settings models.PomodoroModel) *models.PhaseStep {
+	if len(settings.PhaseSequence) == 0 {
+		return nil
+	}
+	index := settings.SequenceIndex
+	if index < 0 || index >= len(settings.PhaseSequence) {
+		index = 0
+	}
+	return &settings.PhaseSequence[index]
+}
+
+// index of a phase name in the custom sequence, -1 if missing
+func sequenceIndexOf(settings models.PomodoroModel, phase string) int {
+	for i, step := range settings.PhaseSequence {
+		if step.Name == phase {
+			return i
+		}
+	}
+	return -1
+}
+
+// length of a phase in seconds
+func PhaseDuration(settings models.PomodoroModel, phase string) int {
+	if len(settings.PhaseSequence) > 0 {
+		if step := currentStep(settings); step != nil && step.Name == phase {
+			return step.Duration * 60
+		}
+		if i := sequenceIndexOf(settings, phase); i >= 0 {
+			return settings.PhaseSequence[i].Duration * 60
+		}
+	}
+
+	switch phase {
+	case "shortBreak":
+		return settings.ShortBreakDuration * 60
+	case "longBreak":
+		return settings.LongBreakDuration * 60
+	default:
+		return settings.PomodoroDuration * 60
+	}
+}
+
+// whether the current phase is focused work
+func IsFocusPhase(settings models.PomodoroModel) bool {
+	if step := currentStep(settings); step != nil {
+		return step.Focus
+	}
+	return settings.CurrentPhase == "pomodoro"
+}
+
+// move the timer to a named phase with its full duration
+func SelectPhase(settings *models.PomodoroModel, phase string) {
+	if i := sequenceIndexOf(*settings, phase); i >= 0 {
+		settings.SequenceIndex = i
+	}
+	settings.CurrentPhase = phase
+	settings.RemainingTime = PhaseDuration(*settings, phase)
+}
+
+// allowed source states of every timer action
+var timerTransitions = map[string][]string{
+	"start":   {models.TimerStateIdle, models.TimerStateFinished},
+	"stop":    {models.TimerStateRunning, models.TimerStatePaused},
+	"pause":   {models.TimerStateRunning},
+	"resume":  {models.TimerStatePaused},
+	"skip":    {models.TimerStateIdle, models.TimerStateRunning, models.TimerStatePaused, models.TimerStateFinished},
+	"abandon": {models.TimerStateRunning, models.TimerStatePaused},
+}
+
+// current state, rows saved before states existed only know IsRunning
+func TimerState(settings models.PomodoroModel) string {
+	if settings.IsRunning {
+		return models.TimerStateRunning
+	}
+	if settings.State == "" || settings.State == models.TimerStateRunning {
+		return models.TimerStateIdle
+	}
+	return settings.State
+}
+
+// whether the action is allowed from the current state
+func CanTransition(settings models.PomodoroModel, action string) bool {
+	return slices.Contains(timerTransitions[action], TimerState(settings))
+}
+
+// whether the phase name exists in the classic cycle or the custom sequence
+func IsValidPhase(settings models.PomodoroModel, phase string) bool {
+	if len(settings.PhaseSequence) > 0 {
+		return sequenceIndexOf(settings, phase) >= 0
+	}
+	return phase == "pomodoro" || phase == "shortBreak" || phase == "longBreak"
+}
+
+// remaining seconds of the current phase at the given moment
+func remainingTimeAt(settings models.PomodoroModel, at time.Time) int {
+	if !settings.IsRunning || settings.PhaseEndsAt == nil {
+		return settings.RemainingTime
+	}
+
+	remaining := int(settings.PhaseEndsAt.Sub(at).Round(time.Second).Seconds())
+	if remaining < 0 {
+		return 0
+	}
+	return remaining
+}
+
+// remaining seconds of the current phase, derived from the deadline while running
+func RemainingTime(settings models.PomodoroModel) int {
+	return remainingTimeAt(settings, time.Now())
+}
+
+// run the remaining time of the current phase from now on and schedule its end
+func runPhase(settings *models.PomodoroModel, now time.Time) {
+	setPhaseRun(settings, now)
+	pomodoroScheduler.schedule(settings.UserID, *settings.PhaseEndsAt)
+}
+
+// mark the current phase as running from now on without scheduling its end
+func setPhaseRun(settings *models.PomodoroModel, now time.Time) {
+	endsAt := now.Add(time.Duration(settings.RemainingTime) * time.Second)
+
+	settings.IsRunning = true
+	settings.State = models.TimerStateRunning
+	settings.PhaseEndsAt = &endsAt
+}
+
+// clear the run of the current phase, remaining time is kept as is
+func clearPhaseRun(settings *models.PomodoroModel, state string) {
+	settings.IsRunning = false
+	settings.State = state
+	settings.PhaseStartedAt = nil
+	settings.PhaseEndsAt = nil
+	settings.PausedAt = nil
+	settings.PausedSeconds = 0
+}
+
+// start a new run of the current phase
+func StartPomodoroTimer(settings *models.PomodoroModel) {
+	if settings.RemainingTime <= 0 {
+		settings.RemainingTime = PhaseDuration(*settings, settings.CurrentPhase)
+	}
+
+	//db keeps whole seconds only, so deadlines must compare equal after a reload
+	now := time.Now().Truncate(time.Second)
+
+	settings.PhaseStartedAt = &now
+	settings.PausedAt = nil
+	settings.PausedSeconds = 0
+	runPhase(settings, now)
+}
+
+// freeze the remaining time, record the aborted run and drop the scheduled phase end
+func StopPomodoroTimer(settings *models.PomodoroModel) {
+	now := time.Now().Truncate(time.Second)
+	if settings.PhaseStartedAt != nil {
+		recordPomodoroSession(*settings, now, false)
+	}
+
+	settings.RemainingTime = remainingTimeAt(*settings, now)
+	clearPhaseRun(settings, models.TimerStateIdle)
+
+	pomodoroScheduler.cancel(settings.UserID)
+}
+
+// stop the run and reset the current phase to its full length
+func AbandonPomodoroTimer(settings *models.PomodoroModel) {
+	StopPomodoroTimer(settings)
+	settings.RemainingTime = PhaseDuration(*settings, settings.CurrentPhase)
+}
+
+// freeze the remaining time, the run continues on resume
+func PausePomodoroTimer(settings *models.PomodoroModel) {
+	now := time.Now().Truncate(time.Second)
 
-	settings, err := cache.GetPomodoroSettingsByUserID(userID)
-	if err != nil {
+	settings.RemainingTime = remainingTimeAt(*settings, now)
+	settings.IsRunning = false
+	settings.State = models.TimerStatePaused
+	settings.PhaseEndsAt = nil
+	settings.PausedAt = &now
+
+	pomodoroScheduler.cancel(settings.UserID)
+}
+
+// continue a paused run and count the pause
+func ResumePomodoroTimer(settings *models.PomodoroModel) {
+	now := time.Now().Truncate(time.Second)
+	if settings.PausedAt != nil {
+		settings.PausedSeconds += int(now.Sub(*settings.PausedAt).Seconds())
+	}
+	settings.PausedAt = nil
+
+	runPhase(settings, now)
+}
+
+// abort the current run and move to the next phase, a running timer keeps running
+func SkipPomodoroPhase(settings *models.PomodoroModel) {
+	wasRunning := settings.IsRunning
+
+	StopPomodoroTimer(settings)
+	nextPhase(settings, false)
+
+	if wasRunning {
+		StartPomodoroTimer(settings)
+	}
+}
+
+// switch to the next phase, counting a finished pomodoro
+func nextPhase(settings *models.PomodoroModel, completed bool) {
+	counted := completed && IsFocusPhase(*settings)
+	if counted {
+		settings.TotalCompletedPomodoros++
+		settings.CompletedPomodoros++
+	}
+
+	//custom sequence is walked in order and starts over after the last step
+	if len(settings.PhaseSequence) > 0 {
+		settings.SequenceIndex = (settings.SequenceIndex + 1) % len(settings.PhaseSequence)
+		settings.CurrentPhase = settings.PhaseSequence[settings.SequenceIndex].Name
+		settings.RemainingTime = PhaseDuration(*settings, settings.CurrentPhase)
 		return
 	}
 
-	go func() {
-		timer := time.NewTicker(1 * time.Second)
-		defer timer.Stop()
+	interval := settings.LongBreakInterval
+	if interval < 1 {
+		interval = 4
+	}
+
+	switch settings.CurrentPhase {
+	case "pomodoro":
+		if counted && settings.CompletedPomodoros%interval == 0 {
+			settings.CurrentPhase = "longBreak"
+		} else {
+			settings.CurrentPhase = "shortBreak"
+		}
+	case "shortBreak", "longBreak":
+		settings.CurrentPhase = "pomodoro"
+	}
+	settings.RemainingTime = PhaseDuration(*settings, settings.CurrentPhase)
+}
 
-		for {
-			<-timer.C
-			timerMutex.Lock()
+// finish the current phase at the given moment and continue or stop depending on auto transition.
+// nothing is scheduled or recorded here, the caller does that once the new state is saved,
+// returns the finished run for its session row
+func completePhase(settings *models.PomodoroModel, at time.Time) models.PomodoroModel {
+	finished := *settings
+	nextPhase(settings, true)
 
-			updatedSettings, err := cache.GetPomodoroSettingsByUserID(userID)
-			if err != nil {
-				timerMutex.Unlock()
-				return
-			}
-			settings = updatedSettings
+	if settings.AutoTransition {
+		// Continue to next phase
+		settings.PhaseStartedAt = &at
+		settings.PausedSeconds = 0
+		setPhaseRun(settings, at)
+		return finished
+	}
 
-			if !settings.IsRunning {
-				timerMutex.Unlock()
-				return
-			}
+	// Stop timer
+	clearPhaseRun(settings, models.TimerStateFinished)
+	return finished
+}
 
-			if settings.RemainingTime <= 0 {
-				// Switch to the next phase
-				switch settings.CurrentPhase {
-				case "pomodoro":
-					settings.TotalCompletedPomodoros++
-					settings.CompletedPomodoros++
-					if settings.CompletedPomodoros%4 == 0 {
-						settings.CurrentPhase = "longBreak"
-						settings.RemainingTime = settings.LongBreakDuration * 60
-					} else {
-						settings.CurrentPhase = "shortBreak"
-						settings.RemainingTime = settings.ShortBreakDuration * 60
-					}
-				case "shortBreak":
-					settings.CurrentPhase = "pomodoro"
-					settings.RemainingTime = settings.PomodoroDuration * 60
-				case "longBreak":
-					settings.CurrentPhase = "pomodoro"
-					settings.RemainingTime = settings.PomodoroDuration * 60
-				}
+// called by the scheduler when a deadline is reached
+func handlePhaseEnd(userID uint, deadline time.Time) {
+	unlock := LockPomodoro(userID)
+	defer unlock()
 
-				if settings.AutoTransition {
-					settings.IsRunning = true // Continue to next phase
-				} else {
-					settings.IsRunning = false // Stop timer
-				}
+	var settings models.PomodoroModel
+	if err := initializers.DB.First(&settings, "user_id = ?", userID).Error; err != nil {
+		log.Printf("Failed to load pomodoro of user %d: %v", userID, err)
+		return
+	}
 
-				cache.InvalidatePomodoroCache(userID)
-				if err := initializers.DB.Save(&settings).Error; err != nil {
-					timerMutex.Unlock()
-					return
-				}
+	//timer was stopped or restarted after this deadline was scheduled
+	if !settings.IsRunning || settings.PhaseEndsAt == nil || !settings.PhaseEndsAt.Equal(deadline) {
+		return
+	}
 
-				cache.CachePomodoroSettings(settings)
+	finished := completePhase(&settings, deadline)
 
-				if !settings.AutoTransition {
-					timerMutex.Unlock()
-					return
+	cache.InvalidatePomodoroCache(userID)
+	if err := initializers.DB.Save(&settings).Error; err != nil {
+		//the stored run still ends at deadline, so the retry passes the check above
+		log.Printf("Failed to save pomodoro of user %d: %v", userID, err)
+		time.AfterFunc(phaseEndRetryDelay, func() { handlePhaseEnd(userID, deadline) })
+		return
+	}
+
+	if settings.IsRunning {
+		pomodoroScheduler.schedule(userID, *settings.PhaseEndsAt)
+	}
+	recordPomodoroSession(finished, deadline, true)
+	cache.CachePomodoroSettings(settings)
+	BroadcastPomodoro(settings, "phaseCompleted")
+
+	go EmitAchievementEvent(userID, EventPomodoroCompleted)
+}
+
+// upper bound of phases completed for one timer while the server was down
+const maxRecoveredPhases = 100
+
+// wait before a phase end whose state could not be saved is handled again
+const phaseEndRetryDelay = 10 * time.Second
+
+// phase finished during recovery, recorded once the timer is saved
+type completedRun struct {
+	settings models.PomodoroModel
+	endedAt  time.Time
+}
+
+// resume timers that were running when the server stopped
+func RecoverPomodoroTimers() {
+	var running []models.PomodoroModel
+	if err := initializers.DB.Where("is_running = ?", true).Find(&running).Error; err != nil {
+		log.Printf("Failed to load running pomodoros: %v", err)
+		return
+	}
+
+	now := time.Now()
+	for _, settings := range running {
+		unlock := LockPomodoro(settings.UserID)
+
+		var finished []completedRun
+		settings.State = models.TimerStateRunning
+		if settings.PhaseEndsAt == nil {
+			//no deadline stored, continue from the saved remaining time
+			StartPomodoroTimer(&settings)
+		} else {
+			//complete every phase whose deadline passed during the downtime
+			for i := 0; settings.IsRunning && !settings.PhaseEndsAt.After(now); i++ {
+				if i == maxRecoveredPhases {
+					StopPomodoroTimer(&settings)
+					break
 				}
+				endedAt := *settings.PhaseEndsAt
+				finished = append(finished, completedRun{completePhase(&settings, endedAt), endedAt})
+			}
+		}
 
-				timerMutex.Unlock()
-				continue
+		cache.InvalidatePomodoroCache(settings.UserID)
+		if err := initializers.DB.Save(&settings).Error; err != nil {
+			log.Printf("Failed to save recovered pomodoro of user %d: %v", settings.UserID, err)
+		} else {
+			//deadline still ahead, schedule it in this process
+			if settings.IsRunning && settings.PhaseEndsAt != nil {
+				pomodoroScheduler.schedule(settings.UserID, *settings.PhaseEndsAt)
 			}
+			for _, run := range finished {
+				recordPomodoroSession(run.settings, run.endedAt, true)
+			}
+			cache.CachePomodoroSettings(settings)
+			go EmitAchievementEvent(settings.UserID, EventPomodoroCompleted)
+		}
 
-			settings.RemainingTime--
+		unlock()
+	}
 
-			cache.InvalidatePomodoroCache(userID)
-			initializers.DB.Save(&settings)
+	log.Printf("Recovered %d running pomodoro timers", len(running))
+}
 
-			cache.CachePomodoroSettings(settings)
+// replace the custom sequence, a stopped timer moves to the first phase of the new cycle
+func SetPhaseSequence(settings *models.PomodoroModel, sequence []models.PhaseStep) {
+	if slices.Equal(settings.PhaseSequence, sequence) {
+		return
+	}
+	settings.PhaseSequence = sequence
 
-			timerMutex.Unlock()
-		}
-	}()
+	//started phase keeps going, the next phase is taken from the new cycle
+	if state := TimerState(*settings); state == models.TimerStateRunning || state == models.TimerStatePaused {
+		settings.SequenceIndex = max(sequenceIndexOf(*settings, settings.CurrentPhase), 0)
+		return
+	}
+
+	settings.SequenceIndex = 0
+	if len(sequence) > 0 {
+		SelectPhase(settings, sequence[0].Name)
+	} else {
+		SelectPhase(settings, "pomodoro")
+	}
 }
@@ -0,0 +1,51 @@
+package utils
+
+import (
+	"server/models"
+	"time"
+)
+
+// location of the user timezone, UTC when unset or unknown
+func UserLocation(user models.User) *time.Location {
+	if user.Timezone == "" {
+		return time.UTC
+	}
+
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// validates an IANA timezone name
+func IsValidTimezone(name string) bool {
+	if name == "" || name == "Local" {
+		return false
+	}
+	_, err := time.LoadLocation(name)
+	return err == nil
+}
+
+// midnight of the day of t in loc
+func StartOfDay(t time.Time, loc *time.Location) time.Time {
+	t = t.In(loc)
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, loc)
+}
+
+// monday of the week of t in loc
+func StartOfWeek(t time.Time, loc *time.Location) time.Time {
+	day := StartOfDay(t, loc)
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// calendar days from a to b in loc, DST days count as one day
+func DaysBetween(a, b time.Time, loc *time.Location) int {
+	a = a.In(loc)
+	b = b.In(loc)
+
+	//compare dates in UTC so 23h and 25h days dont skew the division
+	dayA := time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	dayB := time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(dayB.Sub(dayA).Hours() / 24)
+}
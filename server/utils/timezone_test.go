@@ -0,0 +1,108 @@
+package utils
+
+import (
+	"server/models"
+	"testing"
+	"time"
+)
+
+func TestStartOfDayAroundDST(t *testing.T) {
+	tests := []struct {
+		zone  string
+		day   time.Time
+		hours float64
+	}{
+		{"America/New_York", time.Date(2024, time.March, 10, 12, 0, 0, 0, time.UTC), 23},
+		{"America/New_York", time.Date(2024, time.November, 3, 12, 0, 0, 0, time.UTC), 25},
+		{"Europe/Riga", time.Date(2024, time.March, 31, 12, 0, 0, 0, time.UTC), 23},
+		{"Europe/Riga", time.Date(2024, time.October, 27, 12, 0, 0, 0, time.UTC), 25},
+	}
+
+	for _, test := range tests {
+		loc := mustLocation(t, test.zone)
+		start := StartOfDay(test.day, loc)
+		next := StartOfDay(start.Add(26*time.Hour), loc)
+
+		if start.Hour() != 0 || start.Minute() != 0 {
+			t.Errorf("%s %s: day starts at %s", test.zone, test.day.Format(time.DateOnly), start.Format(time.TimeOnly))
+		}
+		if hours := next.Sub(start).Hours(); hours != test.hours {
+			t.Errorf("%s %s: day is %vh long, want %vh", test.zone, test.day.Format(time.DateOnly), hours, test.hours)
+		}
+		if days := DaysBetween(start, next, loc); days != 1 {
+			t.Errorf("%s %s: %d days to the next midnight, want 1", test.zone, test.day.Format(time.DateOnly), days)
+		}
+	}
+}
+
+func TestDaysBetweenAcrossDST(t *testing.T) {
+	newYork := mustLocation(t, "America/New_York")
+
+	//late evening before the switch to just after midnight of the switch day
+	before := time.Date(2024, time.March, 9, 23, 30, 0, 0, newYork)
+	after := time.Date(2024, time.March, 10, 0, 30, 0, 0, newYork)
+	if days := DaysBetween(before, after, newYork); days != 1 {
+		t.Errorf("spring forward: %d days, want 1", days)
+	}
+
+	//both ends of a 25h day are the same day
+	morning := time.Date(2024, time.November, 3, 0, 10, 0, 0, newYork)
+	night := time.Date(2024, time.November, 3, 23, 50, 0, 0, newYork)
+	if days := DaysBetween(morning, night, newYork); days != 0 {
+		t.Errorf("fall back: %d days, want 0", days)
+	}
+
+	//a whole week over the switch
+	if days := DaysBetween(time.Date(2024, time.March, 7, 8, 0, 0, 0, newYork), time.Date(2024, time.March, 14, 8, 0, 0, 0, newYork), newYork); days != 7 {
+		t.Errorf("week over the switch: %d days, want 7", days)
+	}
+}
+
+func TestStartOfWeekAcrossDST(t *testing.T) {
+	riga := mustLocation(t, "Europe/Riga")
+
+	//sunday of the switch belongs to the week starting monday the 25th
+	monday := StartOfWeek(time.Date(2024, time.March, 31, 22, 0, 0, 0, riga), riga)
+	if got := monday.Format("2006-01-02 15:04"); got != "2024-03-25 00:00" {
+		t.Errorf("week starts %s, want 2024-03-25 00:00", got)
+	}
+
+	next := StartOfWeek(time.Date(2024, time.April, 1, 0, 30, 0, 0, riga), riga)
+	if got := next.Format("2006-01-02 15:04"); got != "2024-04-01 00:00" {
+		t.Errorf("week starts %s, want 2024-04-01 00:00", got)
+	}
+}
+
+func TestUserDayInUTCPlus10(t *testing.T) {
+	brisbane := mustLocation(t, "Australia/Brisbane")
+	user := models.User{Timezone: "Australia/Brisbane"}
+
+	//9am and 8pm local fall on different UTC dates but on one local day
+	morning := time.Date(2024, time.June, 10, 9, 0, 0, 0, brisbane)
+	evening := time.Date(2024, time.June, 10, 20, 0, 0, 0, brisbane)
+	if morning.UTC().Day() == evening.UTC().Day() {
+		t.Fatal("example should span two UTC dates")
+	}
+
+	loc := UserLocation(user)
+	if days := DaysBetween(morning, evening, loc); days != 0 {
+		t.Errorf("9am to 8pm local is %d days apart, want 0", days)
+	}
+	if !StartOfDay(morning, loc).Equal(StartOfDay(evening, loc)) {
+		t.Error("9am and 8pm local start different days")
+	}
+	if days := DaysBetween(evening, morning.AddDate(0, 0, 1), loc); days != 1 {
+		t.Errorf("8pm to next 9am local is %d days apart, want 1", days)
+	}
+}
+
+func TestUserLocationFallback(t *testing.T) {
+	for _, zone := range []string{"", "Mars/Olympus"} {
+		if loc := UserLocation(models.User{Timezone: zone}); loc != time.UTC {
+			t.Errorf("timezone %q gave %v, want UTC", zone, loc)
+		}
+	}
+	if IsValidTimezone("Local") || IsValidTimezone("") {
+		t.Error("Local and empty timezones must be rejected")
+	}
+}
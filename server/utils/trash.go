@@ -0,0 +1,88 @@
+package utils
+
+import (
+	"gorm.io/gorm"
+	"log"
+	"os"
+	"server/cache"
+	"server/initializers"
+	"server/models"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultTrashRetentionDays = 30
+	trashPurgePeriod          = time.Hour
+)
+
+// days a deleted task stays in the trash, TRASH_RETENTION_DAYS overrides the default
+func TrashRetentionDays() int {
+	if days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS")); err == nil && days > 0 {
+		return days
+	}
+	return defaultTrashRetentionDays
+}
+
+// delete checklist items and tag links of the tasks selected by the ids query
+func DeleteTaskRelations(tx *gorm.DB, ids *gorm.DB) error {
+	if err := tx.Unscoped().Where("task_id IN (?)", ids).Delete(&models.TaskItem{}).Error; err != nil {
+		return err
+	}
+	return tx.Exec("DELETE FROM task_tags WHERE task_id IN (?)", ids).Error
+}
+
+// permanently delete trashed tasks matching the scope with their checklist and tag links
+func PurgeTrashedTasks(db *gorm.DB, scope func(*gorm.DB) *gorm.DB) (int64, error) {
+	var purged int64
+	err := db.Transaction(func(tx *gorm.DB) error {
+		trashed := tx.Unscoped().Model(&models.TasksModel{}).Select("id").Where("deleted_at IS NOT NULL").Scopes(scope)
+		if err := DeleteTaskRelations(tx, trashed); err != nil {
+			return err
+		}
+
+		result := tx.Unscoped().Where("deleted_at IS NOT NULL").Scopes(scope).Delete(&models.TasksModel{})
+		purged = result.RowsAffected
+		return result.Error
+	})
+	return purged, err
+}
+
+// purge tasks and task changes past their retention period in the background
+func StartTrashPurgeWorker() {
+	go func() {
+		PurgeExpiredTrash(time.Now())
+		PurgeTaskChanges(time.Now())
+
+		ticker := time.NewTicker(trashPurgePeriod)
+		defer ticker.Stop()
+		for now := range ticker.C {
+			PurgeExpiredTrash(now)
+			PurgeTaskChanges(now)
+		}
+	}()
+}
+
+// permanently delete tasks trashed before the retention period
+func PurgeExpiredTrash(now time.Time) {
+	before := now.AddDate(0, 0, -TrashRetentionDays())
+
+	var users []uint
+	if err := initializers.DB.Unscoped().Model(&models.TasksModel{}).
+		Where("deleted_at < ?", before).
+		Distinct().Pluck("user_id", &users).Error; err != nil {
+		log.Printf("Failed to load expired trash: %v", err)
+		return
+	}
+
+	for _, userID := range users {
+		_, err := PurgeTrashedTasks(initializers.DB, func(db *gorm.DB) *gorm.DB {
+			return db.Where("user_id = ? AND deleted_at < ?", userID, before)
+		})
+		if err != nil {
+			log.Printf("Failed to purge trash of user %d: %v", userID, err)
+			continue
+		}
+		cache.InvalidateUserTaskCaches(userID)
+	}
+}
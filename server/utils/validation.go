@@ -1,6 +1,9 @@
 package utils
 
-import "regexp"
+import (
+	"regexp"
+	"server/models"
+)
 
 // validates an email address format
 func IsValidEmail(email string) bool {
@@ -43,3 +46,27 @@ func IsValidDescription(description string) bool {
 
 	return isLongEnough
 }
+
+func IsValidItemTitle(title string) bool {
+	return len(title) >= 1 && len(title) <= 200
+}
+
+// validates a custom timer cycle (1-20 steps, names up to 30 chars, 1-180 min and at least one focus step)
+func IsValidPhaseSequence(sequence []models.PhaseStep) bool {
+	if len(sequence) == 0 || len(sequence) > 20 {
+		return false
+	}
+
+	hasFocus := false
+	for _, step := range sequence {
+		if step.Name == "" || len(step.Name) > 30 {
+			return false
+		}
+		if step.Duration < 1 || step.Duration > 180 {
+			return false
+		}
+		hasFocus = hasFocus || step.Focus
+	}
+
+	return hasFocus
+}